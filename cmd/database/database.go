@@ -1,40 +1,86 @@
 package database
 
 import (
+	"chat/config"
+	"context"
 	"fmt"
 	"log"
-	"os"
+	"net/url"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-
-	"github.com/joho/godotenv"
 )
 
-var DB *gorm.DB
-
-func ConnectDB() {
-	_ = godotenv.Load()
+// ConnectDB opens the pool described by cfg, the caller owns the returned handle
+func ConnectDB(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	dialector, err := dialector(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	dbHost := os.Getenv("DB_HOST")
-	dbPort := os.Getenv("DB_PORT")
-	dbUser := os.Getenv("DB_USER")
-	dbPassword := os.Getenv("DB_PASSWORD")
-	dbName := os.Getenv("DB_NAME")
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
 
-	if dbHost == "" || dbPort == "" || dbUser == "" || dbPassword == "" || dbName == "" {
-		log.Fatal("❌ Database configuration is missing")
+	if cfg.Driver == config.DBDriverSQLite {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		// sqlite has a single writer, and every connection to ":memory:" is its own database
+		sqlDB.SetMaxOpenConns(1)
 	}
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		dbUser, dbPassword, dbHost, dbPort, dbName)
+	log.Printf("✅ Connected to %s database successfully", cfg.Driver)
+	return db, nil
+}
 
-	var err error
-	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+// Ping checks that the pool can still reach the database
+func Ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatalf("❌ Failed to connect to database: %v", err)
+		return err
 	}
+	return sqlDB.PingContext(ctx)
+}
+
+func dialector(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case config.DBDriverMySQL:
+		// RowsAffected on mysql only counts rows whose values actually changed, conditional updates
+		// in repository always change what they match so they behave the same as on postgres and sqlite.
+		// Don't add clientFoundRows, it makes ON DUPLICATE KEY UPDATE report duplicates as inserted
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+			cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name)
+		return mysql.Open(dsn), nil
 
-	fmt.Println("✅ Connected to database successfully")
+	case config.DBDriverPostgres:
+		// tanpa password pgx masih bisa pakai ~/.pgpass
+		user := url.User(cfg.User)
+		if cfg.Password != "" {
+			user = url.UserPassword(cfg.User, cfg.Password)
+		}
+		dsn := url.URL{
+			Scheme:   "postgres",
+			User:     user,
+			Host:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+			Path:     cfg.Name,
+			RawQuery: url.Values{"sslmode": {cfg.SSLMode}}.Encode(),
+		}
+		return postgres.Open(dsn.String()), nil
 
+	case config.DBDriverSQLite:
+		// foreign keys are off by default in sqlite, the cascades in model rely on them
+		dsn := cfg.Path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+		if cfg.Path != ":memory:" {
+			dsn += "&_pragma=journal_mode(WAL)"
+		}
+		return sqlite.Open(dsn), nil
+
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", cfg.Driver)
+	}
 }
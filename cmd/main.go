@@ -3,49 +3,195 @@ package main
 import (
 	"chat/cmd/database"
 	router "chat/cmd/routes"
+	"chat/config"
 
 	"chat/internal/handler"
+	"chat/internal/mail"
+	"chat/internal/migration"
+	"chat/internal/realtime"
 	"chat/internal/repository"
 	"chat/internal/usecase"
+	"chat/internal/worker"
+	migrationfiles "chat/migrations"
+	"chat/utils"
+	"chat/utils/logger"
+	"chat/utils/metrics"
+	"chat/utils/middleware"
+	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
-
-	"github.com/joho/godotenv"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("⚠ No .env file found, using system environment variables")
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	appLogger, err := logger.New(os.Stdout, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	// log.Printf yang lama ikut keluar lewat slog
+	slog.SetDefault(appLogger)
+
+	db, err := database.ConnectDB(cfg.Database)
+	if err != nil {
+		log.Fatalf("❌ Failed to connect to database: %v", err)
+	}
+	if err := metrics.InstrumentDB(db); err != nil {
+		log.Fatalf("❌ Failed to instrument database: %v", err)
+	}
+
+	jwtManager := utils.NewJWTManager(cfg.JWT.Secret)
+
+	transport, err := mail.NewTransport(cfg.Mail)
+	if err != nil {
+		log.Fatalf("❌ Failed to set up mailer: %v", err)
+	}
+	templates, err := mail.NewTemplates(cfg.Server.BaseURL, cfg.Server.PasswordResetURL)
+	if err != nil {
+		log.Fatalf("❌ Failed to parse email templates: %v", err)
+	}
+
+	// worker background jalan sampai server selesai shutdown
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	runWorker := func(run func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workerCtx)
+		}()
 	}
 
-	database.ConnectDB()
+	hub := realtime.NewHub(cfg.Server.AllowedOrigins)
+	runWorker(hub.Run)
+
+	// email keluar lewat tabel outbox, dikirim worker di background
+	emailRepo := repository.NewEmailRepository(db)
+	mailer := mail.NewQueueMailer(emailRepo)
+	dispatcher := worker.NewEmailDispatcher(emailRepo, transport, mailer.Enqueued(), cfg.Mail.Workers, cfg.Mail.MaxAttempts)
+	runWorker(dispatcher.Run)
 
 	//auth
-	userRepo := repository.NewUserRepository(database.DB)
-	userUsecase := usecase.NewUserUsecase(userRepo)
+	userRepo := repository.NewUserRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	resetRepo := repository.NewPasswordResetRepository(db)
+	userUsecase := usecase.NewUserUsecase(userRepo, sessionRepo, resetRepo, mailer, templates, jwtManager)
 	userHandler := handler.NewUserHandler(userUsecase)
+	authMiddleware := middleware.JWTAuthMiddleware(jwtManager, sessionRepo)
 
 	//roomchat
-	roomChatRepo := repository.NewRoomChatRepository(database.DB)
-	roomChatUseCase := usecase.NewRoomChatUseCase(roomChatRepo)
+	roomChatRepo := repository.NewRoomChatRepository(db)
+	roomChatUseCase := usecase.NewRoomChatUseCase(roomChatRepo, hub)
 	roomChatHandler := handler.NewRoomChatUserHandler(roomChatUseCase)
 
 	//chat
-	chatRepo := repository.NewChatRepository(database.DB)
-	chatUseCase := usecase.NewChatUsecase(chatRepo, roomChatRepo)
+	chatRepo := repository.NewChatRepository(db)
+	reactionRepo := repository.NewReactionRepository(db)
+	mentionRepo := repository.NewMentionRepository(db)
+	chatUseCase := usecase.NewChatUsecase(chatRepo, roomChatRepo, reactionRepo, mentionRepo, hub)
 	chatHandler := handler.NewChatHandler(chatUseCase)
 
+	// tombstone deleted chats, 0 keeps them forever
+	if retention := cfg.Chat.TombstoneRetention; retention > 0 {
+		cleaner := worker.NewTombstoneCleaner(chatRepo, retention, min(retention, time.Hour))
+		runWorker(cleaner.Run)
+	}
+
+	//admin
+	emailUseCase := usecase.NewEmailUsecase(emailRepo)
+	emailHandler := handler.NewEmailHandler(emailUseCase)
+	adminMiddleware := middleware.AdminOnly(cfg.AdminUserIDs)
+
+	//health
+	migrations, err := migration.Load(migrationfiles.FS, cfg.Database.Driver)
+	if err != nil {
+		log.Fatalf("❌ Failed to read embedded migrations: %v", err)
+	}
+	healthUseCase := usecase.NewHealthUsecase(
+		usecase.HealthCheck{Name: "database", Check: func(ctx context.Context) error { return database.Ping(ctx, db) }},
+		usecase.HealthCheck{Name: "migrations", Check: migration.NewMigrator(db, migrations).CheckVersion},
+		usecase.HealthCheck{Name: "mailer", Optional: !cfg.Mail.RequiredForReady, Check: func(ctx context.Context) error { return mail.Check(ctx, transport) }},
+	)
+	healthHandler := handler.NewHealthHandler(healthUseCase)
+
+	//realtime
+	realtimeHandler := handler.NewRealtimeHandler(hub, roomChatUseCase)
+
 	// Setup Router
-	r := router.SetupRoutes(userHandler, roomChatHandler, chatHandler)
+	r := router.SetupRoutes(userHandler, roomChatHandler, chatHandler, realtimeHandler, emailHandler, healthHandler, authMiddleware, adminMiddleware,
+		metrics.Handler(cfg.Metrics.Token), middleware.RequestLogger(appLogger), middleware.HTTPMetrics)
 
 	// Mulai Server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Server.Port),
+		Handler:           r,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+	}
+	// websocket sudah di-hijack jadi tidak ikut ditunggu Shutdown, hub yang menutupnya
+	server.RegisterOnShutdown(hub.Close)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("🚀 Server running on http://localhost:%d", cfg.Server.Port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		log.Fatalf("❌ Server stopped: %v", err)
+	case <-ctx.Done():
 	}
+	stop()
+	log.Println("🛑 Shutting down, draining connections...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
 
-	fmt.Println("🚀 Server running on http://localhost:" + port)
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	// berhenti terima koneksi baru lalu tunggu request yang masih jalan
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("❌ HTTP server did not drain in time: %v", err)
+	}
+	if err := hub.Wait(shutdownCtx); err != nil {
+		log.Printf("❌ Realtime connections did not close in time: %v", err)
+	}
+	// email reset password dan notifikasi yang masih di jalan
+	if err := userUsecase.Wait(shutdownCtx); err != nil {
+		log.Printf("❌ Background emails did not finish in time: %v", err)
+	}
+
+	stopWorkers()
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-shutdownCtx.Done():
+		log.Println("❌ Background workers did not stop in time")
+	}
+
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			log.Printf("❌ Failed to close database: %v", err)
+		}
+	}
+	log.Println("✅ Server stopped")
 }
@@ -2,21 +2,125 @@ package main
 
 import (
 	"chat/cmd/database"
-	models "chat/model"
+	"chat/config"
+	"chat/internal/migration"
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"strconv"
+	"time"
 )
 
+const usage = `usage: migrate [-dir migrations] <command>
+
+commands:
+  up           jalankan semua migration yang belum jalan
+  down [N]     rollback N migration terakhir (default 1)
+  status       lihat migration yang sudah dan belum jalan
+  create NAME  buat file up/down baru untuk semua driver
+  unlock       hapus lock yang tertinggal dari proses migrate yang crash
+`
+
 func main() {
-	database.ConnectDB()
+	dir := flag.String("dir", "migrations", "folder migration, isinya satu folder per driver database")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
 
-	if database.DB == nil {
-		log.Fatal("❌ Database belum diinisialisasi")
+	// create tidak butuh koneksi database
+	if args[0] == "create" {
+		if len(args) != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		files, err := migration.Create(*dir, args[1])
+		if err != nil {
+			log.Fatalf("❌ Gagal membuat migration: %v", err)
+		}
+		for _, f := range files {
+			log.Printf("✅ %s", f)
+		}
+		return
 	}
 
-	err := database.DB.AutoMigrate(&models.User{}, &models.RoomChat{}, &models.RoomMember{}, &models.Chat{})
+	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("❌ Gagal melakukan migrasi: %v", err)
+		log.Fatalf("❌ %v", err)
 	}
 
-	log.Println("✅ Migrasi sukses!")
+	migrations, err := migration.Load(os.DirFS(*dir), cfg.Database.Driver)
+	if err != nil {
+		log.Fatalf("❌ Gagal membaca migration: %v", err)
+	}
+
+	db, err := database.ConnectDB(cfg.Database)
+	if err != nil {
+		log.Fatalf("❌ Database belum diinisialisasi: %v", err)
+	}
+	migrator := migration.NewMigrator(db, migrations)
+
+	switch args[0] {
+	case "up":
+		done, err := migrator.Up()
+		for _, m := range done {
+			log.Printf("✅ up %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("❌ Gagal melakukan migrasi: %v", err)
+		}
+		if len(done) == 0 {
+			log.Println("✅ Schema sudah versi terbaru")
+		}
+
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			n, err = strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatalf("❌ N harus angka positif")
+			}
+		}
+		done, err := migrator.Down(n)
+		for _, m := range done {
+			log.Printf("✅ down %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("❌ Gagal rollback: %v", err)
+		}
+
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			switch {
+			case s.Missing:
+				state += " (file hilang)"
+			case s.Modified:
+				state += " (file berubah)"
+			}
+			fmt.Printf("%04d  %-40s %s\n", s.Version, s.Name, state)
+		}
+
+	case "unlock":
+		if err := migrator.Unlock(); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		log.Println("✅ Lock dilepas")
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
 }
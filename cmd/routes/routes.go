@@ -2,34 +2,58 @@ package router
 
 import (
 	"chat/internal/handler"
-	"chat/utils/middleware"
 	"net/http"
 
 	"github.com/gorilla/mux"
 )
 
-func SetupRoutes(userHandler *handler.UserHandler, roomChatHandler *handler.RoomChatHandler, chatHandler *handler.ChatHandler) *mux.Router {
+func SetupRoutes(userHandler *handler.UserHandler, roomChatHandler *handler.RoomChatHandler, chatHandler *handler.ChatHandler, realtimeHandler *handler.RealtimeHandler, emailHandler *handler.EmailHandler, healthHandler *handler.HealthHandler, authMiddleware, adminMiddleware mux.MiddlewareFunc, metricsHandler http.Handler, requestMiddlewares ...mux.MiddlewareFunc) *mux.Router {
 	r := mux.NewRouter()
 
+	// access log & metrics untuk semua request, yang tidak ketemu route juga
+	r.Use(requestMiddlewares...)
+	r.NotFoundHandler = wrap(http.NotFoundHandler(), requestMiddlewares)
+	r.MethodNotAllowedHandler = wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}), requestMiddlewares)
+
+	r.Handle("/metrics", metricsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/healthz", healthHandler.Healthz).Methods(http.MethodGet)
+	r.HandleFunc("/readyz", healthHandler.Readyz).Methods(http.MethodGet)
+
 	//auth
 	r.HandleFunc("/register", userHandler.Register).Methods(http.MethodPost)
 	r.HandleFunc("/login", userHandler.Login).Methods(http.MethodPost)
+	r.HandleFunc("/login/2fa", userHandler.LoginMFA).Methods(http.MethodPost)
 	r.HandleFunc("/verification", userHandler.Verifikasi).Methods(http.MethodGet)
 	r.HandleFunc("/resendlink/verif", userHandler.ResendLinkVerif).Methods(http.MethodPost)
+	r.HandleFunc("/refresh", userHandler.Refresh).Methods(http.MethodPost)
+	r.HandleFunc("/password/forgot", userHandler.ForgotPassword).Methods(http.MethodPost)
+	r.HandleFunc("/password/reset", userHandler.ResetPassword).Methods(http.MethodPost)
+	r.Handle("/logout", authMiddleware(http.HandlerFunc(userHandler.Logout))).Methods(http.MethodPost)
+	r.Handle("/logout-all", authMiddleware(http.HandlerFunc(userHandler.LogoutAll))).Methods(http.MethodPost)
 
 	userRouter := r.PathPrefix("/user").Subrouter()
-	userRouter.Use(middleware.JWTAuthMiddleware)
+	userRouter.Use(authMiddleware)
 
 	//user
 	userRouter.HandleFunc("/delete", userHandler.DeleteUser).Methods(http.MethodDelete)
 	userRouter.HandleFunc("/update", userHandler.UpdateUser).Methods(http.MethodPut)
+	userRouter.HandleFunc("/password", userHandler.ChangePassword).Methods(http.MethodPut)
+	userRouter.HandleFunc("/2fa/enroll", userHandler.EnrollTOTP).Methods(http.MethodPost)
+	userRouter.HandleFunc("/2fa/confirm", userHandler.ConfirmTOTP).Methods(http.MethodPost)
+	userRouter.HandleFunc("/2fa/disable", userHandler.DisableTOTP).Methods(http.MethodPost)
+	userRouter.HandleFunc("/mentions", chatHandler.GetMentions).Methods(http.MethodGet)
+	userRouter.HandleFunc("/mentions/read", chatHandler.MarkMentionsRead).Methods(http.MethodPost)
+	userRouter.HandleFunc("/presence", realtimeHandler.SetPresence).Methods(http.MethodPost)
 
 	chatRouter := r.PathPrefix("/chat").Subrouter()
-	chatRouter.Use(middleware.JWTAuthMiddleware)
+	chatRouter.Use(authMiddleware)
 
 	chatRouter.HandleFunc("/getroom", roomChatHandler.GetRoomChatByUserId).Methods(http.MethodGet)
 	chatRouter.HandleFunc("/getroom/{id}", roomChatHandler.GetRoomChatById).Methods(http.MethodGet)
 	chatRouter.HandleFunc("/createroom", roomChatHandler.CreateRoom).Methods(http.MethodPost)
+	chatRouter.HandleFunc("/direct/{userId:[0-9]+}", roomChatHandler.GetOrCreateDirectRoom).Methods(http.MethodPost)
 	chatRouter.HandleFunc("/deleteroom/{roomId}", roomChatHandler.DeleteRoom).Methods(http.MethodDelete)
 	chatRouter.HandleFunc("/updateroom/{roomId}", roomChatHandler.UpdateRoom).Methods(http.MethodPut)
 
@@ -41,9 +65,43 @@ func SetupRoutes(userHandler *handler.UserHandler, roomChatHandler *handler.Room
 
 	//chat
 	chatRouter.HandleFunc("/createchat/{roomId}", chatHandler.CreateChat).Methods(http.MethodPost)
-	chatRouter.HandleFunc("/updatechat/{roomId}", chatHandler.UpdateChat).Methods(http.MethodPut)
-	chatRouter.HandleFunc("/deletechat/{roomId}", chatHandler.DeleteChat).Methods(http.MethodDelete)
 	chatRouter.HandleFunc("/getchat/{roomId}", chatHandler.GetChat).Methods(http.MethodGet)
 
+	//single message
+	chatRouter.HandleFunc("/{roomId:[0-9]+}/messages/{chatId:[0-9]+}", chatHandler.UpdateChat).Methods(http.MethodPut)
+	chatRouter.HandleFunc("/{roomId:[0-9]+}/messages/{chatId:[0-9]+}", chatHandler.DeleteChat).Methods(http.MethodDelete)
+	chatRouter.HandleFunc("/{roomId:[0-9]+}/messages/{chatId:[0-9]+}/purge", chatHandler.PurgeChat).Methods(http.MethodDelete)
+	chatRouter.HandleFunc("/{roomId:[0-9]+}/messages/{chatId:[0-9]+}/revisions", chatHandler.GetChatRevisions).Methods(http.MethodGet)
+	chatRouter.HandleFunc("/{roomId:[0-9]+}/messages/{chatId:[0-9]+}/replies", chatHandler.GetReplies).Methods(http.MethodGet)
+	chatRouter.HandleFunc("/{roomId:[0-9]+}/messages/{chatId:[0-9]+}/seen", chatHandler.GetSeenBy).Methods(http.MethodGet)
+
+	//read receipt
+	chatRouter.HandleFunc("/{roomId:[0-9]+}/read", chatHandler.MarkRead).Methods(http.MethodPost)
+
+	//reaction
+	chatRouter.HandleFunc("/{roomId:[0-9]+}/messages/{chatId:[0-9]+}/reactions", chatHandler.AddReaction).Methods(http.MethodPost)
+	chatRouter.HandleFunc("/{roomId:[0-9]+}/messages/{chatId:[0-9]+}/reactions/{emoji}", chatHandler.RemoveReaction).Methods(http.MethodDelete)
+
+	//realtime
+	chatRouter.HandleFunc("/events", realtimeHandler.ServeSSE).Methods(http.MethodGet)
+	chatRouter.HandleFunc("/{roomId:[0-9]+}/presence", realtimeHandler.GetRoomPresence).Methods(http.MethodGet)
+	chatRouter.HandleFunc("/{roomId:[0-9]+}/typing", realtimeHandler.Typing).Methods(http.MethodPost)
+	r.Handle("/ws", authMiddleware(http.HandlerFunc(realtimeHandler.ServeWS))).Methods(http.MethodGet)
+
+	//admin
+	adminRouter := r.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(authMiddleware, adminMiddleware)
+
+	adminRouter.HandleFunc("/emails", emailHandler.GetEmails).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/emails/{id:[0-9]+}/resend", emailHandler.ResendEmail).Methods(http.MethodPost)
+
 	return r
 }
+
+// wrap applies middlewares in the same order Router.Use does, first one outermost
+func wrap(h http.Handler, middlewares []mux.MiddlewareFunc) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
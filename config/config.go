@@ -0,0 +1,396 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is loaded once at startup, later sources win: defaults, CONFIG_FILE (yaml), .env, environment
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	JWT      JWTConfig      `yaml:"jwt"`
+	Mail     MailConfig     `yaml:"mail"`
+	Chat     ChatConfig     `yaml:"chat"`
+	Log      LogConfig      `yaml:"log"`
+	Metrics  MetricsConfig  `yaml:"metrics"`
+
+	// id user admin, boleh akses /admin. Bukan email karena email bisa diganti user sendiri
+	AdminUserIDs []uint `yaml:"admin_user_ids"`
+}
+
+type ServerConfig struct {
+	Port int `yaml:"port"`
+	// base URL publik untuk link di email, default http://localhost:<port>
+	BaseURL string `yaml:"base_url"`
+	// halaman reset password di client, token ditambahkan sebagai ?token=. Kosong = email cuma berisi token
+	PasswordResetURL string `yaml:"password_reset_url"`
+	// origin browser yang boleh buka websocket, default cuma origin dari BaseURL
+	AllowedOrigins []string `yaml:"allowed_origins"`
+
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	// SSE dan websocket tidak kena timeout ini, deadline-nya diatur sendiri per koneksi
+	WriteTimeout   time.Duration `yaml:"write_timeout"`
+	IdleTimeout    time.Duration `yaml:"idle_timeout"`
+	MaxHeaderBytes int           `yaml:"max_header_bytes"`
+	// batas waktu drain request dan koneksi realtime saat SIGINT/SIGTERM
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+const (
+	DBDriverMySQL    = "mysql"
+	DBDriverPostgres = "postgres"
+	DBDriverSQLite   = "sqlite"
+)
+
+type DatabaseConfig struct {
+	// mysql, postgres atau sqlite
+	Driver string `yaml:"driver"`
+	// file database untuk sqlite, ":memory:" juga bisa
+	Path string `yaml:"path"`
+	// sslmode postgres
+	SSLMode string `yaml:"sslmode"`
+
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+}
+
+type JWTConfig struct {
+	Secret string `yaml:"secret"`
+}
+
+const (
+	MailDriverSMTP   = "smtp"
+	MailDriverOutbox = "outbox"
+)
+
+type MailConfig struct {
+	// smtp atau outbox (tulis ke OutboxDir / stdout, buat development)
+	Driver    string `yaml:"driver"`
+	OutboxDir string `yaml:"outbox_dir"`
+
+	SMTPHost string `yaml:"smtp_host"`
+	SMTPPort int    `yaml:"smtp_port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+
+	Workers     int `yaml:"workers"`
+	MaxAttempts int `yaml:"max_attempts"`
+
+	// /readyz gagal kalau mailer tidak bisa dihubungi. Matikan kalau email cukup antri di outbox
+	// dan SMTP mati tidak perlu mengeluarkan instance dari load balancer
+	RequiredForReady bool `yaml:"required_for_ready"`
+}
+
+type ChatConfig struct {
+	// chat yang dihapus dibuang permanen setelah ini, 0 = simpan selamanya
+	TombstoneRetention time.Duration `yaml:"tombstone_retention"`
+}
+
+type LogConfig struct {
+	// debug, info, warn atau error
+	Level string `yaml:"level"`
+	// json atau text
+	Format string `yaml:"format"`
+}
+
+type MetricsConfig struct {
+	// kalau diisi, scraper harus kirim "Authorization: Bearer <token>" ke /metrics
+	Token string `yaml:"token"`
+}
+
+func defaults() Config {
+	return Config{
+		Server: ServerConfig{
+			Port:              8080,
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       time.Minute,
+			MaxHeaderBytes:    1 << 20,
+			ShutdownTimeout:   15 * time.Second,
+		},
+		Database: DatabaseConfig{Driver: DBDriverMySQL, SSLMode: "disable"},
+		Mail: MailConfig{
+			Driver:      MailDriverSMTP,
+			SMTPHost:    "smtp.gmail.com",
+			SMTPPort:    587,
+			Workers:     4,
+			MaxAttempts: 5,
+
+			RequiredForReady: true,
+		},
+		Chat: ChatConfig{TombstoneRetention: 30 * 24 * time.Hour},
+		Log:  LogConfig{Level: "info", Format: "json"},
+	}
+}
+
+// Load reads and validates the config, the error lists every problem at once
+func Load() (*Config, error) {
+	// .env is optional, real environment variables are not overridden by it
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("load .env: %w", err)
+	}
+
+	cfg := defaults()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read CONFIG_FILE: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+
+	env := envReader{}
+	env.int(&cfg.Server.Port, "PORT")
+	env.string(&cfg.Server.BaseURL, "APP_BASE_URL")
+	env.string(&cfg.Server.PasswordResetURL, "APP_PASSWORD_RESET_URL")
+	env.list(&cfg.Server.AllowedOrigins, "APP_ALLOWED_ORIGINS")
+	env.duration(&cfg.Server.ReadHeaderTimeout, "SERVER_READ_HEADER_TIMEOUT")
+	env.duration(&cfg.Server.ReadTimeout, "SERVER_READ_TIMEOUT")
+	env.duration(&cfg.Server.WriteTimeout, "SERVER_WRITE_TIMEOUT")
+	env.duration(&cfg.Server.IdleTimeout, "SERVER_IDLE_TIMEOUT")
+	env.int(&cfg.Server.MaxHeaderBytes, "SERVER_MAX_HEADER_BYTES")
+	env.duration(&cfg.Server.ShutdownTimeout, "SERVER_SHUTDOWN_TIMEOUT")
+
+	env.string(&cfg.Database.Driver, "DB_DRIVER")
+	env.string(&cfg.Database.Path, "DB_PATH")
+	env.string(&cfg.Database.SSLMode, "DB_SSLMODE")
+	env.string(&cfg.Database.Host, "DB_HOST")
+	env.int(&cfg.Database.Port, "DB_PORT")
+	env.string(&cfg.Database.User, "DB_USER")
+	env.string(&cfg.Database.Password, "DB_PASSWORD")
+	env.string(&cfg.Database.Name, "DB_NAME")
+
+	env.string(&cfg.JWT.Secret, "JWT_SECRET")
+
+	env.string(&cfg.Mail.Driver, "MAIL_DRIVER")
+	env.string(&cfg.Mail.OutboxDir, "MAIL_OUTBOX_DIR")
+	env.string(&cfg.Mail.SMTPHost, "SMTP_HOST")
+	env.int(&cfg.Mail.SMTPPort, "SMTP_PORT")
+	env.string(&cfg.Mail.Username, "EMAIL_SENDER")
+	env.string(&cfg.Mail.Password, "APP_PASSWORD")
+	env.string(&cfg.Mail.From, "MAIL_FROM")
+	env.int(&cfg.Mail.Workers, "MAIL_WORKERS")
+	env.int(&cfg.Mail.MaxAttempts, "MAIL_MAX_ATTEMPTS")
+	env.bool(&cfg.Mail.RequiredForReady, "MAIL_REQUIRED_FOR_READY")
+
+	env.duration(&cfg.Chat.TombstoneRetention, "CHAT_TOMBSTONE_RETENTION")
+
+	env.string(&cfg.Log.Level, "LOG_LEVEL")
+	env.string(&cfg.Log.Format, "LOG_FORMAT")
+
+	env.string(&cfg.Metrics.Token, "METRICS_TOKEN")
+	env.ids(&cfg.AdminUserIDs, "ADMIN_USER_IDS")
+	if os.Getenv("ADMIN_EMAILS") != "" {
+		env.errs = append(env.errs, errors.New("ADMIN_EMAILS is no longer supported, list admin user ids in ADMIN_USER_IDS"))
+	}
+
+	if cfg.Database.Port == 0 {
+		switch cfg.Database.Driver {
+		case DBDriverMySQL:
+			cfg.Database.Port = 3306
+		case DBDriverPostgres:
+			cfg.Database.Port = 5432
+		}
+	}
+
+	if cfg.Server.BaseURL == "" {
+		cfg.Server.BaseURL = fmt.Sprintf("http://localhost:%d", cfg.Server.Port)
+	}
+	cfg.Server.BaseURL = strings.TrimRight(cfg.Server.BaseURL, "/")
+	if len(cfg.Server.AllowedOrigins) == 0 {
+		cfg.Server.AllowedOrigins = []string{cfg.Server.BaseURL}
+	}
+
+	if err := errors.Join(append(env.errs, cfg.Validate()...)...); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
+	return &cfg, nil
+}
+
+// Validate returns one error per problem, named by env variable
+func (c *Config) Validate() []error {
+	var errs []error
+	required := func(value, key string) {
+		if strings.TrimSpace(value) == "" {
+			errs = append(errs, fmt.Errorf("%s is required", key))
+		}
+	}
+	port := func(value int, key string) {
+		if value <= 0 || value > 65535 {
+			errs = append(errs, fmt.Errorf("%s must be between 1 and 65535, got %d", key, value))
+		}
+	}
+
+	port(c.Server.Port, "PORT")
+	if !strings.HasPrefix(c.Server.BaseURL, "http://") && !strings.HasPrefix(c.Server.BaseURL, "https://") {
+		errs = append(errs, fmt.Errorf("APP_BASE_URL must start with http:// or https://, got %q", c.Server.BaseURL))
+	}
+	if u := c.Server.PasswordResetURL; u != "" && !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		errs = append(errs, fmt.Errorf("APP_PASSWORD_RESET_URL must start with http:// or https://, got %q", u))
+	}
+	for _, origin := range c.Server.AllowedOrigins {
+		if !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			errs = append(errs, fmt.Errorf("APP_ALLOWED_ORIGINS entries must start with http:// or https://, got %q", origin))
+		}
+	}
+	positive := func(value time.Duration, key string) {
+		if value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %s", key, value))
+		}
+	}
+	positive(c.Server.ReadHeaderTimeout, "SERVER_READ_HEADER_TIMEOUT")
+	positive(c.Server.ReadTimeout, "SERVER_READ_TIMEOUT")
+	positive(c.Server.WriteTimeout, "SERVER_WRITE_TIMEOUT")
+	positive(c.Server.IdleTimeout, "SERVER_IDLE_TIMEOUT")
+	positive(c.Server.ShutdownTimeout, "SERVER_SHUTDOWN_TIMEOUT")
+	if c.Server.MaxHeaderBytes < 1024 {
+		errs = append(errs, fmt.Errorf("SERVER_MAX_HEADER_BYTES must be at least 1024, got %d", c.Server.MaxHeaderBytes))
+	}
+
+	switch c.Database.Driver {
+	case DBDriverMySQL, DBDriverPostgres:
+		required(c.Database.Host, "DB_HOST")
+		port(c.Database.Port, "DB_PORT")
+		required(c.Database.User, "DB_USER")
+		// DB_PASSWORD boleh kosong, mis. trust/peer auth atau ~/.pgpass
+		required(c.Database.Name, "DB_NAME")
+	case DBDriverSQLite:
+		required(c.Database.Path, "DB_PATH")
+	default:
+		errs = append(errs, fmt.Errorf("DB_DRIVER must be %q, %q or %q, got %q", DBDriverMySQL, DBDriverPostgres, DBDriverSQLite, c.Database.Driver))
+	}
+
+	required(c.JWT.Secret, "JWT_SECRET")
+
+	switch c.Mail.Driver {
+	case MailDriverSMTP:
+		required(c.Mail.SMTPHost, "SMTP_HOST")
+		port(c.Mail.SMTPPort, "SMTP_PORT")
+		required(c.Mail.Username, "EMAIL_SENDER")
+		required(c.Mail.Password, "APP_PASSWORD")
+	case MailDriverOutbox:
+	default:
+		errs = append(errs, fmt.Errorf("MAIL_DRIVER must be %q or %q, got %q", MailDriverSMTP, MailDriverOutbox, c.Mail.Driver))
+	}
+	if c.Mail.Workers < 1 {
+		errs = append(errs, fmt.Errorf("MAIL_WORKERS must be at least 1, got %d", c.Mail.Workers))
+	}
+	if c.Mail.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("MAIL_MAX_ATTEMPTS must be at least 1, got %d", c.Mail.MaxAttempts))
+	}
+
+	if c.Chat.TombstoneRetention < 0 {
+		errs = append(errs, fmt.Errorf("CHAT_TOMBSTONE_RETENTION can't be negative, got %s", c.Chat.TombstoneRetention))
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error, got %q", c.Log.Level))
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "json", "text":
+	default:
+		errs = append(errs, fmt.Errorf("LOG_FORMAT must be json or text, got %q", c.Log.Format))
+	}
+	return errs
+}
+
+// envReader overrides a field only when the variable is set and not empty, parse errors are collected
+type envReader struct {
+	errs []error
+}
+
+func (e *envReader) string(dst *string, key string) {
+	if v := os.Getenv(key); v != "" {
+		*dst = v
+	}
+}
+
+func (e *envReader) int(dst *int, key string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s must be a number, got %q", key, v))
+		return
+	}
+	*dst = n
+}
+
+func (e *envReader) bool(dst *bool, key string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s must be true or false, got %q", key, v))
+		return
+	}
+	*dst = b
+}
+
+func (e *envReader) duration(dst *time.Duration, key string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s must be a duration like 720h, got %q", key, v))
+		return
+	}
+	*dst = d
+}
+
+func (e *envReader) list(dst *[]string, key string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*dst = items
+}
+
+func (e *envReader) ids(dst *[]uint, key string) {
+	var items []string
+	e.list(&items, key)
+	if items == nil {
+		return
+	}
+	ids := make([]uint, 0, len(items))
+	for _, item := range items {
+		id, err := strconv.ParseUint(item, 10, 0)
+		if err != nil || id == 0 {
+			e.errs = append(e.errs, fmt.Errorf("%s must be comma separated user ids, got %q", key, item))
+			return
+		}
+		ids = append(ids, uint(id))
+	}
+	*dst = ids
+}
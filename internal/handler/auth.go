@@ -3,9 +3,9 @@ package handler
 import (
 	"chat/internal/usecase"
 	"chat/utils"
+	"chat/utils/logger"
 	"chat/utils/middleware"
 	"encoding/json"
-	"fmt"
 	"net/http"
 )
 
@@ -62,15 +62,124 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := utils.GenerateJWTLogin(user.ID, user.Email)
+	// 2fa aktif: password benar baru setengah jalan, JWT asli keluar di /login/2fa
+	if user.TOTPEnabled {
+		mfaToken, err := h.userUC.StartMFA(user)
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, "failed generate token")
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"message":      "masukkan kode 2FA",
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+			"expires_in":   int64(utils.MFAPendingTTL.Seconds()),
+		})
+		return
+	}
+
+	tokens, err := h.userUC.StartSession(user)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, "failed generate token")
 		return
 	}
 
 	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
-		"message": "berhasil login",
-		"token":   token,
+		"message":       "berhasil login",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+	})
+}
+
+func (h *UserHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.MFAToken == "" {
+		utils.WriteError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	tokens, err := h.userUC.VerifyMFA(input.MFAToken, input.Code)
+	if err != nil {
+		switch err {
+		case utils.ErrInvalidToken, utils.ErrInvalidCode, utils.ErrUserNotFound:
+			utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		case utils.ErrTooManyTries:
+			utils.WriteError(w, http.StatusTooManyRequests, err.Error())
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"message":       "berhasil login",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+	})
+}
+
+func (h *UserHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.RefreshToken == "" {
+		utils.WriteError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	tokens, err := h.userUC.Refresh(input.RefreshToken)
+	if err != nil {
+		switch err {
+		case utils.ErrInvalidToken, utils.ErrTokenReused:
+			utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, tokens)
+}
+
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
+
+	if err := h.userUC.Logout(claims.SessionID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{
+		"message": "berhasil logout",
+	})
+}
+
+func (h *UserHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
+
+	if err := h.userUC.LogoutAll(claims.UserID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{
+		"message": "berhasil logout dari semua device",
 	})
 }
 
@@ -103,6 +212,42 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
+
+	var input struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	if err := h.userUC.ChangePassword(claims.Email, claims.SessionID, input.CurrentPassword, input.NewPassword); err != nil {
+		switch err {
+		case utils.ErrWrongPassword:
+			utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		case utils.ErrWeakPassword, utils.ErrSamePassword:
+			utils.WriteError(w, http.StatusBadRequest, err.Error())
+		case utils.ErrUserNotFound:
+			utils.WriteError(w, http.StatusNotFound, err.Error())
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{
+		"message": "password berhasil diubah, device lain sudah logout",
+	})
+}
+
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
 	if !ok {
@@ -110,8 +255,10 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fmt.Println(claims.Email)
+	log := logger.FromContext(r.Context())
+	log.Info("deleting user", "email", claims.Email)
 	if err := h.userUC.DeleteUser(claims.Email); err != nil {
+		log.Warn("delete user failed", "email", claims.Email, "error", err)
 		http.Error(w, "User not found", http.StatusNotFound)
 		return
 	}
@@ -124,13 +271,13 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 func (h *UserHandler) Verifikasi(w http.ResponseWriter, r *http.Request) {
 	tokenString := r.URL.Query().Get("token")
 
-	claims, err := utils.ParseJWT(tokenString)
-	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, "jwt error")
-		return
-	}
-	if err := h.userUC.ValidateUser(claims.Email); err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, "failed verified user")
+	if err := h.userUC.VerifyAccount(tokenString); err != nil {
+		switch err {
+		case utils.ErrInvalidToken:
+			utils.WriteError(w, http.StatusInternalServerError, "jwt error")
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, "failed verified user")
+		}
 		return
 	}
 
@@ -157,3 +304,141 @@ func (h *UserHandler) ResendLinkVerif(w http.ResponseWriter, r *http.Request) {
 		"massage": "succed send link verification",
 	})
 }
+
+func (h *UserHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	if err := h.userUC.ForgotPassword(input.Email); err != nil {
+		switch err {
+		case utils.ErrBadRequest:
+			utils.WriteError(w, http.StatusBadRequest, "invalid email format")
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, "failed to send link")
+		}
+		return
+	}
+
+	// sama untuk email yang terdaftar maupun tidak
+	utils.WriteJSON(w, http.StatusOK, map[string]string{
+		"message": "if the email is registered, a reset link has been sent",
+	})
+}
+
+func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Token == "" {
+		utils.WriteError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	if err := h.userUC.ResetPassword(input.Token, input.Password); err != nil {
+		switch err {
+		case utils.ErrInvalidToken, utils.ErrWeakPassword:
+			utils.WriteError(w, http.StatusBadRequest, err.Error())
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{
+		"message": "password berhasil direset, silakan login lagi",
+	})
+}
+
+func (h *UserHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
+
+	enroll, err := h.userUC.EnrollTOTP(claims.Email)
+	if err != nil {
+		writeTOTPError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, enroll)
+}
+
+func (h *UserHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
+
+	var input struct {
+		Code string `json:"code"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	codes, err := h.userUC.ConfirmTOTP(claims.Email, input.Code)
+	if err != nil {
+		writeTOTPError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"message":        "2FA aktif, simpan recovery code ini baik-baik",
+		"recovery_codes": codes,
+	})
+}
+
+func (h *UserHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
+
+	var input struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	if err := h.userUC.DisableTOTP(claims.Email, input.Password, input.Code); err != nil {
+		writeTOTPError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{
+		"message": "2FA dinonaktifkan",
+	})
+}
+
+func writeTOTPError(w http.ResponseWriter, err error) {
+	switch err {
+	case utils.ErrInvalidCode, utils.ErrWrongPassword:
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+	case utils.ErrTOTPEnabled, utils.ErrTOTPDisabled:
+		utils.WriteError(w, http.StatusConflict, err.Error())
+	case utils.ErrUserNotFound:
+		utils.WriteError(w, http.StatusNotFound, err.Error())
+	case utils.ErrTooManyTries:
+		utils.WriteError(w, http.StatusTooManyRequests, err.Error())
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, err.Error())
+	}
+}
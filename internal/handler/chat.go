@@ -1,10 +1,13 @@
 package handler
 
 import (
+	"chat/internal/repository"
 	"chat/internal/usecase"
 	"chat/utils"
 	"chat/utils/middleware"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -30,7 +33,8 @@ func (h *ChatHandler) CreateChat(w http.ResponseWriter, r *http.Request) {
 	roomId, _ := strconv.Atoi(params["roomId"])
 
 	var input struct {
-		Message string `json:"message"`
+		Message  string `json:"message"`
+		ParentID *uint  `json:"parent_id"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
@@ -43,10 +47,10 @@ func (h *ChatHandler) CreateChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response, err := h.chatUC.CreateChat(input.Message, uint(roomId), claims.UserID)
+	response, err := h.chatUC.CreateChat(input.Message, uint(roomId), claims.UserID, input.ParentID)
 	if err != nil {
 		switch err {
-		case utils.ErrRoomNotFound:
+		case utils.ErrRoomNotFound, utils.ErrChatNotFound:
 			utils.WriteError(w, http.StatusNotFound, err.Error())
 		case utils.ErrUnauthorized:
 			utils.WriteError(w, http.StatusUnauthorized, err.Error())
@@ -69,6 +73,7 @@ func (h *ChatHandler) UpdateChat(w http.ResponseWriter, r *http.Request) {
 
 	params := mux.Vars(r)
 	roomId, _ := strconv.Atoi(params["roomId"])
+	chatId, _ := strconv.Atoi(params["chatId"])
 
 	var input struct {
 		Message string `json:"message"`
@@ -84,9 +89,10 @@ func (h *ChatHandler) UpdateChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.chatUC.UpdateChat(uint(roomId), claims.UserID, input.Message); err != nil {
+	response, err := h.chatUC.UpdateChat(uint(roomId), uint(chatId), claims.UserID, input.Message)
+	if err != nil {
 		switch err {
-		case utils.ErrRoomNotFound:
+		case utils.ErrRoomNotFound, utils.ErrChatNotFound:
 			utils.WriteError(w, http.StatusNotFound, err.Error())
 		case utils.ErrUnauthorized:
 			utils.WriteError(w, http.StatusUnauthorized, err.Error())
@@ -95,11 +101,7 @@ func (h *ChatHandler) UpdateChat(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	utils.WriteJSON(w, http.StatusOK, map[string]any{
-		"room_id": roomId,
-		"user_id": claims.UserID,
-		"message": input.Message,
-	})
+	utils.WriteJSON(w, http.StatusOK, response)
 
 }
 
@@ -112,10 +114,11 @@ func (h *ChatHandler) DeleteChat(w http.ResponseWriter, r *http.Request) {
 
 	params := mux.Vars(r)
 	roomId, _ := strconv.Atoi(params["roomId"])
+	chatId, _ := strconv.Atoi(params["chatId"])
 
-	if err := h.chatUC.DeleteChat(uint(roomId), claims.UserID); err != nil {
+	if err := h.chatUC.DeleteChat(uint(roomId), uint(chatId), claims.UserID); err != nil {
 		switch err {
-		case utils.ErrRoomNotFound:
+		case utils.ErrRoomNotFound, utils.ErrChatNotFound:
 			utils.WriteError(w, http.StatusNotFound, err.Error())
 		case utils.ErrUnauthorized:
 			utils.WriteError(w, http.StatusUnauthorized, err.Error())
@@ -124,25 +127,328 @@ func (h *ChatHandler) DeleteChat(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	utils.WriteJSON(w, http.StatusCreated, map[string]string{
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
 		"message": "succeed delete",
+		"id":      chatId,
+	})
+
+}
+func (h *ChatHandler) PurgeChat(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
+
+	params := mux.Vars(r)
+	roomId, _ := strconv.Atoi(params["roomId"])
+	chatId, _ := strconv.Atoi(params["chatId"])
+
+	if err := h.chatUC.PurgeChat(uint(roomId), uint(chatId), claims.UserID); err != nil {
+		switch err {
+		case utils.ErrRoomNotFound, utils.ErrChatNotFound:
+			utils.WriteError(w, http.StatusNotFound, err.Error())
+		case utils.ErrUnauthorized:
+			utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		case utils.ErrHasReplies:
+			utils.WriteError(w, http.StatusConflict, err.Error())
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "succeed purge",
+		"id":      chatId,
 	})
+}
+
+func (h *ChatHandler) GetChatRevisions(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
 
+	params := mux.Vars(r)
+	roomId, _ := strconv.Atoi(params["roomId"])
+	chatId, _ := strconv.Atoi(params["chatId"])
+
+	result, err := h.chatUC.GetChatRevisions(uint(roomId), uint(chatId), claims.UserID)
+	if err != nil {
+		switch err {
+		case utils.ErrRoomNotFound, utils.ErrChatNotFound:
+			utils.WriteError(w, http.StatusNotFound, err.Error())
+		case utils.ErrUnauthorized:
+			utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, result)
 }
+
 func (h *ChatHandler) GetChat(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
+
 	params := mux.Vars(r)
 	roomId, _ := strconv.Atoi(params["roomId"])
 
-	result, err := h.chatUC.GetAllChatByRoomId(uint(roomId))
+	cursor, err := parseChatCursor(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.chatUC.GetAllChatByRoomId(uint(roomId), claims.UserID, cursor)
 	if err != nil {
 		switch err {
+		case utils.ErrRoomNotFound:
+			utils.WriteError(w, http.StatusNotFound, err.Error())
 		case utils.ErrUnauthorized:
+			utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		case utils.ErrBadRequest:
+			utils.WriteError(w, http.StatusBadRequest, "use either before or after, not both")
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, result)
+
+}
+
+func (h *ChatHandler) GetReplies(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
+
+	params := mux.Vars(r)
+	roomId, _ := strconv.Atoi(params["roomId"])
+	chatId, _ := strconv.Atoi(params["chatId"])
+
+	cursor, err := parseChatCursor(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.chatUC.GetReplies(uint(roomId), uint(chatId), claims.UserID, cursor)
+	if err != nil {
+		switch err {
+		case utils.ErrRoomNotFound, utils.ErrChatNotFound:
 			utils.WriteError(w, http.StatusNotFound, err.Error())
+		case utils.ErrUnauthorized:
+			utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		case utils.ErrBadRequest:
+			utils.WriteError(w, http.StatusBadRequest, "use either before or after, not both")
 		default:
 			utils.WriteError(w, http.StatusInternalServerError, err.Error())
 		}
 		return
 	}
 	utils.WriteJSON(w, http.StatusOK, result)
+}
+
+func (h *ChatHandler) AddReaction(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
+
+	params := mux.Vars(r)
+	roomId, _ := strconv.Atoi(params["roomId"])
+	chatId, _ := strconv.Atoi(params["chatId"])
+
+	var input struct {
+		Emoji string `json:"emoji"`
+	}
 
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	if err := h.chatUC.AddReaction(uint(roomId), uint(chatId), claims.UserID, input.Emoji); err != nil {
+		writeReactionError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"chat_id": chatId,
+		"emoji":   input.Emoji,
+	})
+}
+
+func (h *ChatHandler) RemoveReaction(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
+
+	params := mux.Vars(r)
+	roomId, _ := strconv.Atoi(params["roomId"])
+	chatId, _ := strconv.Atoi(params["chatId"])
+	emoji := params["emoji"]
+
+	if err := h.chatUC.RemoveReaction(uint(roomId), uint(chatId), claims.UserID, emoji); err != nil {
+		writeReactionError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "succeed remove reaction",
+		"chat_id": chatId,
+		"emoji":   emoji,
+	})
+}
+
+func (h *ChatHandler) GetMentions(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
+
+	result, err := h.chatUC.GetUnreadMentions(claims.UserID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, result)
+}
+
+func (h *ChatHandler) MarkMentionsRead(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
+
+	// empty ids marks everything as read
+	var input struct {
+		IDs []uint `json:"ids"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	if err := h.chatUC.MarkMentionsRead(claims.UserID, input.IDs); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]string{
+		"message": "succeed mark mentions read",
+	})
+}
+
+func (h *ChatHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
+
+	params := mux.Vars(r)
+	roomId, _ := strconv.Atoi(params["roomId"])
+
+	var input struct {
+		ChatID uint `json:"chat_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.ChatID == 0 {
+		utils.WriteError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	if err := h.chatUC.MarkRead(uint(roomId), input.ChatID, claims.UserID); err != nil {
+		switch err {
+		case utils.ErrRoomNotFound, utils.ErrChatNotFound:
+			utils.WriteError(w, http.StatusNotFound, err.Error())
+		case utils.ErrUnauthorized:
+			utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"room_id":           roomId,
+		"last_read_chat_id": input.ChatID,
+	})
+}
+
+func (h *ChatHandler) GetSeenBy(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
+
+	params := mux.Vars(r)
+	roomId, _ := strconv.Atoi(params["roomId"])
+	chatId, _ := strconv.Atoi(params["chatId"])
+
+	result, err := h.chatUC.GetSeenBy(uint(roomId), uint(chatId), claims.UserID)
+	if err != nil {
+		switch err {
+		case utils.ErrRoomNotFound, utils.ErrChatNotFound:
+			utils.WriteError(w, http.StatusNotFound, err.Error())
+		case utils.ErrUnauthorized:
+			utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		case utils.ErrRoomTooLarge:
+			utils.WriteError(w, http.StatusBadRequest, "seen by is only available for small rooms")
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, result)
+}
+
+func writeReactionError(w http.ResponseWriter, err error) {
+	switch err {
+	case utils.ErrRoomNotFound, utils.ErrChatNotFound:
+		utils.WriteError(w, http.StatusNotFound, err.Error())
+	case utils.ErrUnauthorized:
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+	case utils.ErrBadRequest:
+		utils.WriteError(w, http.StatusBadRequest, "invalid emoji")
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// parseChatCursor reads ?before=, ?after= and ?limit= from the query string
+func parseChatCursor(r *http.Request) (repository.ChatCursor, error) {
+	var cursor repository.ChatCursor
+	query := r.URL.Query()
+
+	for name, dst := range map[string]*uint{"before": &cursor.Before, "after": &cursor.After} {
+		if v := query.Get(name); v != "" {
+			id, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return cursor, fmt.Errorf("invalid %s cursor", name)
+			}
+			*dst = uint(id)
+		}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return cursor, errors.New("invalid limit")
+		}
+		cursor.Limit = limit
+	}
+	return cursor, nil
 }
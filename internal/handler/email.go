@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"chat/internal/usecase"
+	"chat/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type EmailHandler struct {
+	emailUC usecase.EmailUsecase
+}
+
+func NewEmailHandler(emailUC usecase.EmailUsecase) *EmailHandler {
+	return &EmailHandler{emailUC}
+}
+
+// GetEmails lists the outbox, ?status=dead shows the dead letters
+func (h *EmailHandler) GetEmails(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	emails, err := h.emailUC.GetEmails(r.URL.Query().Get("status"), limit)
+	if err != nil {
+		switch err {
+		case utils.ErrBadRequest:
+			utils.WriteError(w, http.StatusBadRequest, "invalid status")
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, emails)
+}
+
+func (h *EmailHandler) ResendEmail(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	emailId, _ := strconv.Atoi(params["id"])
+
+	if err := h.emailUC.ResendEmail(uint(emailId)); err != nil {
+		switch err {
+		case utils.ErrEmailNotFound:
+			utils.WriteError(w, http.StatusNotFound, err.Error())
+		case utils.ErrEmailNotDead:
+			utils.WriteError(w, http.StatusConflict, err.Error())
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"id":      emailId,
+		"message": "email masuk antrian lagi",
+	})
+}
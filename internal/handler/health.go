@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"chat/internal/usecase"
+	"chat/utils"
+	"net/http"
+)
+
+type HealthHandler struct {
+	healthUC usecase.HealthUsecase
+}
+
+func NewHealthHandler(healthUC usecase.HealthUsecase) *HealthHandler {
+	return &HealthHandler{healthUC}
+}
+
+// Healthz liveness, 200 selama proses masih bisa melayani request
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, h.healthUC.Live())
+}
+
+// Readyz readiness, 503 kalau database, schema-nya, atau mailer (kecuali MAIL_REQUIRED_FOR_READY=false) belum siap
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	res, ready := h.healthUC.Ready(r.Context())
+	if !ready {
+		utils.WriteJSON(w, http.StatusServiceUnavailable, res)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"chat/internal/realtime"
+	"chat/internal/usecase"
+	"chat/response"
+	"chat/utils"
+	"chat/utils/middleware"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type RealtimeHandler struct {
+	hub        *realtime.Hub
+	roomChatUC usecase.RoomChatUseCase
+}
+
+func NewRealtimeHandler(hub *realtime.Hub, roomChatUC usecase.RoomChatUseCase) *RealtimeHandler {
+	return &RealtimeHandler{hub, roomChatUC}
+}
+
+func (h *RealtimeHandler) ServeWS(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
+
+	roomIDs, err := h.userRoomIDs(claims.UserID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Upgrade already wrote an http error on failure
+	h.hub.ServeWS(w, r, claims.UserID, roomIDs)
+}
+
+func (h *RealtimeHandler) ServeSSE(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
+
+	// EventSource sends Last-Event-ID on reconnect, the query param covers the first connect
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	var afterID *uint64
+	if lastEventID != "" {
+		id, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "invalid Last-Event-ID")
+			return
+		}
+		afterID = &id
+	}
+
+	roomIDs, err := h.userRoomIDs(claims.UserID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := h.hub.ServeSSE(w, r, claims.UserID, roomIDs, afterID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (h *RealtimeHandler) userRoomIDs(userID uint) ([]uint, error) {
+	rooms, err := h.roomChatUC.GetGroupsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	roomIDs := make([]uint, 0, len(rooms))
+	for _, room := range rooms {
+		roomIDs = append(roomIDs, room.ID)
+	}
+	return roomIDs, nil
+}
+
+// GetRoomPresence returns the presence of every member of the room, only members may ask
+func (h *RealtimeHandler) GetRoomPresence(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
+
+	params := mux.Vars(r)
+	roomId, _ := strconv.Atoi(params["roomId"])
+
+	isMember, err := h.roomChatUC.IsUserInRoom(uint(roomId), claims.UserID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !isMember {
+		utils.WriteError(w, http.StatusUnauthorized, utils.ErrUnauthorized.Error())
+		return
+	}
+
+	members, err := h.roomChatUC.GetRoomMember(uint(roomId))
+	if err != nil {
+		switch err {
+		case utils.ErrRoomNotFound:
+			utils.WriteError(w, http.StatusNotFound, err.Error())
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	presence := make([]response.PresenceResponse, 0, len(members))
+	for _, member := range members {
+		status, lastSeen, typing := h.hub.PresenceOf(uint(roomId), member.UserID)
+		item := response.PresenceResponse{
+			UserID: member.UserID,
+			Role:   member.Role,
+			Status: status,
+			Typing: typing,
+		}
+		if !lastSeen.IsZero() {
+			item.LastSeen = &lastSeen
+		}
+		presence = append(presence, item)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, presence)
+}
+
+// Typing is the http variant of the websocket typing signal, for sse clients
+func (h *RealtimeHandler) Typing(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
+
+	params := mux.Vars(r)
+	roomId, _ := strconv.Atoi(params["roomId"])
+
+	var input struct {
+		Typing bool `json:"typing"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	// the hub only knows rooms of open connections, so this doubles as the membership check
+	if !h.hub.SetTyping(uint(roomId), claims.UserID, input.Typing) {
+		utils.WriteError(w, http.StatusConflict, "no open realtime connection for this room")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"room_id": roomId,
+		"typing":  input.Typing,
+	})
+}
+
+func (h *RealtimeHandler) SetPresence(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
+
+	var input struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	if input.Status != realtime.PresenceOnline && input.Status != realtime.PresenceAway {
+		utils.WriteError(w, http.StatusBadRequest, "status must be online or away")
+		return
+	}
+
+	if !h.hub.SetStatus(claims.UserID, input.Status) {
+		utils.WriteError(w, http.StatusConflict, "no open realtime connection")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"status": input.Status,
+	})
+}
@@ -78,6 +78,36 @@ func (h *RoomChatHandler) CreateRoom(w http.ResponseWriter, r *http.Request) {
 
 }
 
+func (h *RoomChatHandler) GetOrCreateDirectRoom(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized access")
+		return
+	}
+
+	params := mux.Vars(r)
+	peerId, _ := strconv.Atoi(params["userId"])
+
+	room, created, err := h.RoomChatUC.GetOrCreateDirectRoom(claims.UserID, uint(peerId))
+	if err != nil {
+		switch err {
+		case utils.ErrUserNotFound:
+			utils.WriteError(w, http.StatusNotFound, err.Error())
+		case utils.ErrBadRequest:
+			utils.WriteError(w, http.StatusBadRequest, "can't open a direct room with yourself")
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	utils.WriteJSON(w, status, room)
+}
+
 func (u *RoomChatHandler) DeleteRoom(w http.ResponseWriter, r *http.Request) {
 	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.JWTClaims)
 	if !ok {
@@ -136,6 +166,8 @@ func (h *RoomChatHandler) UpdateRoom(w http.ResponseWriter, r *http.Request) {
 			utils.WriteError(w, http.StatusNotFound, err.Error())
 		case utils.ErrUnauthorized:
 			utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		case utils.ErrDirectRoom:
+			utils.WriteError(w, http.StatusBadRequest, err.Error())
 		case utils.ErrInternal:
 			utils.WriteError(w, http.StatusInternalServerError, err.Error())
 		}
@@ -195,6 +227,8 @@ func (h *RoomChatHandler) AddMembers(w http.ResponseWriter, r *http.Request) {
 			utils.WriteError(w, http.StatusNotFound, err.Error())
 		case utils.ErrUnauthorized:
 			utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		case utils.ErrDirectRoom:
+			utils.WriteError(w, http.StatusBadRequest, err.Error())
 		case utils.ErrInternal:
 			utils.WriteError(w, http.StatusInternalServerError, err.Error())
 		}
@@ -231,7 +265,12 @@ func (h *RoomChatHandler) DeleteMembersByAdmin(w http.ResponseWriter, r *http.Re
 	}
 
 	if err := h.RoomChatUC.DeleteMembersByAdmin(uint(roomId), claims.UserID, input.TargetIDS); err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		switch err {
+		case utils.ErrDirectRoom:
+			utils.WriteError(w, http.StatusBadRequest, err.Error())
+		default:
+			utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		}
 		return
 	}
 
@@ -258,6 +297,8 @@ func (h *RoomChatHandler) LeaveRoom(w http.ResponseWriter, r *http.Request) {
 			utils.WriteError(w, http.StatusNotFound, err.Error())
 		case utils.ErrUnauthorized:
 			utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		case utils.ErrDirectRoom:
+			utils.WriteError(w, http.StatusBadRequest, err.Error())
 		case utils.ErrInternal:
 			utils.WriteError(w, http.StatusInternalServerError, err.Error())
 		}
@@ -0,0 +1,29 @@
+package mail
+
+import "context"
+
+// Message is a rendered email, Text is the plain part sent alongside HTML
+type Message struct {
+	To      string
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// Mailer delivers a rendered message, implementations must be safe for concurrent use
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Checker is implemented by mailers that depend on something outside the process
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// Check reports whether m can currently deliver, mailers without a Checker always can
+func Check(ctx context.Context, m Mailer) error {
+	if c, ok := m.(Checker); ok {
+		return c.Check(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OutboxMailer is for development, it writes messages to a directory (one file each)
+// or to stdout instead of sending them
+type OutboxMailer struct {
+	mu  sync.Mutex
+	dir string
+	out io.Writer
+}
+
+// NewOutboxMailer writes to stdout when dir is empty
+func NewOutboxMailer(dir string) (*OutboxMailer, error) {
+	if dir == "" {
+		return &OutboxMailer{out: os.Stdout}, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &OutboxMailer{dir: dir}, nil
+}
+
+func (m *OutboxMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	content := fmt.Sprintf("Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n--- html ---\n%s\n",
+		now.Format(time.RFC1123Z), msg.To, msg.Subject, msg.Text, msg.HTML)
+
+	if m.dir == "" {
+		_, err := fmt.Fprintf(m.out, "📧 ---- outbox ----\n%s📧 ----------------\n", content)
+		return err
+	}
+
+	name := fmt.Sprintf("%s_%s.eml", now.Format("20060102T150405.000000000"), sanitize(msg.To))
+	return os.WriteFile(filepath.Join(m.dir, name), []byte(content), 0o644)
+}
+
+// Check fails when the outbox directory was removed after startup
+func (m *OutboxMailer) Check(ctx context.Context) error {
+	if m.dir == "" {
+		return nil
+	}
+	info, err := os.Stat(m.dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", m.dir)
+	}
+	return nil
+}
+
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '@':
+			return r
+		}
+		return '_'
+	}, s)
+}
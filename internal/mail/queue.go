@@ -0,0 +1,43 @@
+package mail
+
+import (
+	"chat/internal/repository"
+	"chat/model"
+)
+
+// QueueMailer stores the message in the outbox table and returns, the email worker does the sending
+type QueueMailer struct {
+	repo repository.EmailRepository
+	wake chan struct{}
+}
+
+func NewQueueMailer(repo repository.EmailRepository) *QueueMailer {
+	return &QueueMailer{
+		repo: repo,
+		wake: make(chan struct{}, 1),
+	}
+}
+
+func (q *QueueMailer) Send(msg Message) error {
+	email := &model.OutboundEmail{
+		Recipient: msg.To,
+		Subject:   msg.Subject,
+		HTML:      msg.HTML,
+		Text:      msg.Text,
+	}
+	if err := q.repo.Enqueue(email); err != nil {
+		return err
+	}
+
+	// nudge the worker so it doesn't wait for the next poll, a pending nudge is enough
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Enqueued fires after a message is queued
+func (q *QueueMailer) Enqueued() <-chan struct{} {
+	return q.wake
+}
@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"context"
+	"net"
+	"strconv"
+
+	"gopkg.in/gomail.v2"
+)
+
+// SMTPMailer dials the server for every message, a send may take 1-3 seconds
+type SMTPMailer struct {
+	dialer *gomail.Dialer
+	from   string
+}
+
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	if from == "" {
+		from = username
+	}
+	return &SMTPMailer{
+		dialer: gomail.NewDialer(host, port, username, password),
+		from:   from,
+	}
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	message := gomail.NewMessage()
+	message.SetHeader("From", m.from)
+	message.SetHeader("To", msg.To)
+	message.SetHeader("Subject", msg.Subject)
+	message.SetBody("text/plain", msg.Text)
+	message.AddAlternative("text/html", msg.HTML)
+	return m.dialer.DialAndSend(message)
+}
+
+// Check only opens a TCP connection, logging in would count against the provider's rate limit
+func (m *SMTPMailer) Check(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(m.dialer.Host, strconv.Itoa(m.dialer.Port)))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,122 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"net/url"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+const appName = "API Chat"
+
+type kind struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// Templates renders the emails the app sends, links are built on the public base URL.
+// The reset link goes to the client's page instead, POST /password/reset can't be opened from an email
+type Templates struct {
+	baseURL  string
+	resetURL string
+	kinds    map[string]kind
+}
+
+type templateData struct {
+	AppName   string
+	Link      string
+	Token     string
+	ExpiresIn string
+	Title     string
+	Body      string
+}
+
+func NewTemplates(baseURL, resetURL string) (*Templates, error) {
+	t := &Templates{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		resetURL: resetURL,
+		kinds:    make(map[string]kind),
+	}
+
+	for _, name := range []string{"verification", "password_reset", "notification"} {
+		html, err := htmltemplate.ParseFS(templateFS, "templates/layout.html", "templates/"+name+".html")
+		if err != nil {
+			return nil, err
+		}
+		text, err := texttemplate.ParseFS(templateFS, "templates/"+name+".txt")
+		if err != nil {
+			return nil, err
+		}
+		t.kinds[name] = kind{html, text}
+	}
+	return t, nil
+}
+
+func (t *Templates) Verification(to, token string, ttl time.Duration) (Message, error) {
+	return t.render("verification", to, "Verify Your Account", templateData{
+		Link:      t.link("/verification", token),
+		ExpiresIn: humanize(ttl),
+	})
+}
+
+func (t *Templates) PasswordReset(to, token string, ttl time.Duration) (Message, error) {
+	data := templateData{
+		Token:     token,
+		ExpiresIn: humanize(ttl),
+	}
+	if t.resetURL != "" {
+		sep := "?"
+		if strings.Contains(t.resetURL, "?") {
+			sep = "&"
+		}
+		data.Link = t.resetURL + sep + "token=" + url.QueryEscape(token)
+	}
+	return t.render("password_reset", to, "Reset Your Password", data)
+}
+
+// Notification is a plain informational email, path is optional and relative to the base URL
+func (t *Templates) Notification(to, title, body, path string) (Message, error) {
+	data := templateData{Title: title, Body: body}
+	if path != "" {
+		data.Link = t.baseURL + path
+	}
+	return t.render("notification", to, title, data)
+}
+
+func (t *Templates) link(path, token string) string {
+	return t.baseURL + path + "?token=" + url.QueryEscape(token)
+}
+
+func (t *Templates) render(name, to, subject string, data templateData) (Message, error) {
+	data.AppName = appName
+
+	var html bytes.Buffer
+	if err := t.kinds[name].html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return Message{}, err
+	}
+	var text bytes.Buffer
+	if err := t.kinds[name].text.Execute(&text, data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		To:      to,
+		Subject: subject,
+		HTML:    html.String(),
+		Text:    text.String(),
+	}, nil
+}
+
+func humanize(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("%d jam", int(d.Hours()))
+	}
+	return fmt.Sprintf("%d menit", int(d.Minutes()))
+}
@@ -0,0 +1,18 @@
+package mail
+
+import (
+	"chat/config"
+	"fmt"
+)
+
+// NewTransport builds the mailer that actually delivers, picked by cfg.Driver
+func NewTransport(cfg config.MailConfig) (Mailer, error) {
+	switch cfg.Driver {
+	case config.MailDriverOutbox:
+		return NewOutboxMailer(cfg.OutboxDir)
+	case config.MailDriverSMTP:
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.Username, cfg.Password, cfg.From), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
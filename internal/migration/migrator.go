@@ -0,0 +1,255 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrLocked = errors.New("another migration is running")
+
+// schemaMigration satu baris per versi yang sudah dijalankan
+type schemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	Checksum  string    `gorm:"size:64;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string { return "schema_migrations" }
+
+// schemaLock paling banyak satu baris, insert gagal kalau sudah ada yang pegang lock
+type schemaLock struct {
+	ID       int       `gorm:"primaryKey;autoIncrement:false"`
+	LockedBy string    `gorm:"size:255;not null"`
+	LockedAt time.Time `gorm:"not null"`
+}
+
+func (schemaLock) TableName() string { return "schema_lock" }
+
+const lockID = 1
+
+// Status satu migration dibanding isi schema_migrations
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+	// file up sudah berubah sejak dijalankan
+	Modified bool
+	// sudah dijalankan tapi filenya tidak ada lagi
+	Missing bool
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+	owner      string
+}
+
+func NewMigrator(db *gorm.DB, migrations []Migration) *Migrator {
+	host, _ := os.Hostname()
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+		owner:      fmt.Sprintf("%s:%d", host, os.Getpid()),
+	}
+}
+
+// Up runs every pending migration in order and returns the ones it ran
+func (m *Migrator) Up() ([]Migration, error) {
+	var done []Migration
+	err := m.locked(func() error {
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		if err := check(statuses); err != nil {
+			return err
+		}
+
+		var lastApplied int64
+		for _, s := range statuses {
+			if s.AppliedAt != nil {
+				lastApplied = s.Version
+			}
+		}
+
+		for _, s := range statuses {
+			if s.AppliedAt != nil {
+				continue
+			}
+			// riwayat harus linear, versi lama yang baru muncul (mis. dari branch lain) harus di-rename dulu
+			if s.Version < lastApplied {
+				return fmt.Errorf("migration %04d_%s is older than applied version %d, give it a newer version", s.Version, s.Name, lastApplied)
+			}
+			if err := m.apply(s.Migration); err != nil {
+				return err
+			}
+			done = append(done, s.Migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the last n applied migrations, newest first
+func (m *Migrator) Down(n int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(func() error {
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+
+		for i := len(statuses) - 1; i >= 0 && len(done) < n; i-- {
+			s := statuses[i]
+			if s.AppliedAt == nil {
+				continue
+			}
+			if s.Missing {
+				return fmt.Errorf("migration %d is applied but its files are missing", s.Version)
+			}
+			if err := m.revert(s.Migration); err != nil {
+				return err
+			}
+			done = append(done, s.Migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status lists every known version, applied ones whose files are gone included
+func (m *Migrator) Status() ([]Status, error) {
+	if err := m.db.AutoMigrate(&schemaMigration{}, &schemaLock{}); err != nil {
+		return nil, err
+	}
+
+	var applied []schemaMigration
+	if err := m.db.Order("version").Find(&applied).Error; err != nil {
+		return nil, err
+	}
+	appliedByVersion := make(map[int64]schemaMigration, len(applied))
+	for _, a := range applied {
+		appliedByVersion[a.Version] = a
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := Status{Migration: mig}
+		if a, ok := appliedByVersion[mig.Version]; ok {
+			s.AppliedAt = &a.AppliedAt
+			s.Modified = a.Checksum != mig.Checksum
+			delete(appliedByVersion, mig.Version)
+		}
+		statuses = append(statuses, s)
+	}
+	for _, a := range appliedByVersion {
+		appliedAt := a.AppliedAt
+		statuses = append(statuses, Status{
+			Migration: Migration{Version: a.Version, Name: a.Name, Checksum: a.Checksum},
+			AppliedAt: &appliedAt,
+			Missing:   true,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// CheckVersion fails until the database is migrated up to the newest migration this binary ships with.
+// A newer schema is fine, that happens while a rolling deploy migrates ahead of older instances
+func (m *Migrator) CheckVersion(ctx context.Context) error {
+	var current int64
+	err := m.db.WithContext(ctx).Model(&schemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&current).Error
+	if err != nil {
+		return err
+	}
+
+	var expected int64
+	if n := len(m.migrations); n > 0 {
+		expected = m.migrations[n-1].Version
+	}
+	if current < expected {
+		return fmt.Errorf("schema at version %d, expected %d", current, expected)
+	}
+	return nil
+}
+
+// apply runs one migration in a transaction. Postgres and sqlite roll the DDL back on failure,
+// mysql commits every DDL statement on its own so a failed migration there may need manual cleanup
+func (m *Migrator) apply(mig Migration) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range statements(mig.Up) {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("%04d_%s up: %w", mig.Version, mig.Name, err)
+			}
+		}
+		return tx.Create(&schemaMigration{
+			Version:   mig.Version,
+			Name:      mig.Name,
+			Checksum:  mig.Checksum,
+			AppliedAt: time.Now(),
+		}).Error
+	})
+}
+
+func (m *Migrator) revert(mig Migration) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range statements(mig.Down) {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("%04d_%s down: %w", mig.Version, mig.Name, err)
+			}
+		}
+		return tx.Where("version = ?", mig.Version).Delete(&schemaMigration{}).Error
+	})
+}
+
+// locked holds the schema lock while fn runs so two instances can't migrate at the same time
+func (m *Migrator) locked(fn func() error) error {
+	if err := m.db.AutoMigrate(&schemaMigration{}, &schemaLock{}); err != nil {
+		return err
+	}
+
+	lock := schemaLock{ID: lockID, LockedBy: m.owner, LockedAt: time.Now()}
+	if err := m.db.Create(&lock).Error; err != nil {
+		var holder schemaLock
+		if m.db.Where("id = ?", lockID).Take(&holder).Error == nil {
+			return fmt.Errorf("%w: held by %s since %s, run unlock if that process is gone",
+				ErrLocked, holder.LockedBy, holder.LockedAt.Format(time.RFC3339))
+		}
+		return err
+	}
+
+	defer func() {
+		if err := m.db.Where("id = ? AND locked_by = ?", lockID, m.owner).Delete(&schemaLock{}).Error; err != nil {
+			log.Printf("❌ Gagal melepas lock migrasi: %v", err)
+		}
+	}()
+	return fn()
+}
+
+// Unlock drops a lock left behind by a migration process that crashed
+func (m *Migrator) Unlock() error {
+	if err := m.db.AutoMigrate(&schemaLock{}); err != nil {
+		return err
+	}
+	return m.db.Where("id = ?", lockID).Delete(&schemaLock{}).Error
+}
+
+func check(statuses []Status) error {
+	var errs []error
+	for _, s := range statuses {
+		switch {
+		case s.Missing:
+			errs = append(errs, fmt.Errorf("migration %04d_%s is applied but its files are missing", s.Version, s.Name))
+		case s.Modified:
+			errs = append(errs, fmt.Errorf("migration %04d_%s was modified after it was applied, add a new migration instead", s.Version, s.Name))
+		}
+	}
+	return errors.Join(errs...)
+}
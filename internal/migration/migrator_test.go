@@ -0,0 +1,191 @@
+package migration
+
+import (
+	"chat/migrations"
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func openSQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_pragma=foreign_keys(1)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func versions(migs []Migration) []int64 {
+	var v []int64
+	for _, m := range migs {
+		v = append(v, m.Version)
+	}
+	return v
+}
+
+// file migration sqlite yang di-embed harus bisa naik, turun habis, lalu naik lagi
+func TestMigrator_SQLiteUpDown(t *testing.T) {
+	db := openSQLite(t)
+	migs, err := Load(migrations.FS, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMigrator(db, migs)
+
+	if err := m.CheckVersion(context.Background()); err == nil {
+		t.Error("CheckVersion passes on an empty database")
+	}
+
+	done, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(versions(done), versions(migs)) {
+		t.Fatalf("Up ran %v, want %v", versions(done), versions(migs))
+	}
+	if err := m.CheckVersion(context.Background()); err != nil {
+		t.Errorf("CheckVersion after Up: %v", err)
+	}
+	for _, table := range []string{"users", "room_chats", "room_members", "chats", "mentions", "sessions", "outbound_emails"} {
+		if !db.Migrator().HasTable(table) {
+			t.Errorf("table %s missing after Up", table)
+		}
+	}
+	if !db.Migrator().HasColumn("users", "mfa_locked_at") {
+		t.Error("users.mfa_locked_at missing after Up")
+	}
+
+	if done, err := m.Up(); err != nil || len(done) != 0 {
+		t.Fatalf("second Up = %v, %v, want nothing to do", versions(done), err)
+	}
+
+	done, err = m.Down(len(migs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != len(migs) || done[0].Version != migs[len(migs)-1].Version {
+		t.Fatalf("Down ran %v, want all newest first", versions(done))
+	}
+	for _, table := range []string{"users", "chats", "outbound_emails"} {
+		if db.Migrator().HasTable(table) {
+			t.Errorf("table %s still there after Down", table)
+		}
+	}
+
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("Up after Down: %v", err)
+	}
+}
+
+func TestMigrator_Checks(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sqlite/0001_a.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER);")},
+		"sqlite/0001_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"sqlite/0003_c.up.sql":   {Data: []byte("CREATE TABLE c (id INTEGER);")},
+		"sqlite/0003_c.down.sql": {Data: []byte("DROP TABLE c;")},
+	}
+	load := func() []Migration {
+		migs, err := Load(fsys, "sqlite")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return migs
+	}
+
+	db := openSQLite(t)
+	if _, err := NewMigrator(db, load()).Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	// versi lebih lama dari yang sudah jalan, misalnya dari branch lain
+	fsys["sqlite/0002_b.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE b (id INTEGER);")}
+	if _, err := NewMigrator(db, load()).Up(); err == nil {
+		t.Error("Up runs a migration older than the applied version")
+	}
+	delete(fsys, "sqlite/0002_b.up.sql")
+
+	fsys["sqlite/0001_a.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE a (id INTEGER, name TEXT);")}
+	statuses, err := NewMigrator(db, load()).Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !statuses[0].Modified {
+		t.Error("changed up file is not reported as modified")
+	}
+	if _, err := NewMigrator(db, load()).Up(); err == nil {
+		t.Error("Up runs with a modified migration")
+	}
+	fsys["sqlite/0001_a.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE a (id INTEGER);")}
+
+	delete(fsys, "sqlite/0003_c.up.sql")
+	delete(fsys, "sqlite/0003_c.down.sql")
+	statuses, err = NewMigrator(db, load()).Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || !statuses[1].Missing {
+		t.Errorf("applied migration without files is not reported missing: %+v", statuses)
+	}
+	if _, err := NewMigrator(db, load()).Down(1); err == nil {
+		t.Error("Down reverts a migration whose files are missing")
+	}
+}
+
+func TestMigrator_Lock(t *testing.T) {
+	db := openSQLite(t)
+	m := NewMigrator(db, nil)
+	if err := db.AutoMigrate(&schemaLock{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&schemaLock{ID: lockID, LockedBy: "host:1", LockedAt: time.Now()}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Up(); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Up while locked = %v, want ErrLocked", err)
+	}
+	if err := m.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("Up after Unlock: %v", err)
+	}
+}
+
+func TestStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"empty", "-- cuma komentar\n\n", nil},
+		{"one per line", "CREATE TABLE a (id INT);\nDROP TABLE b;\n", []string{"CREATE TABLE a (id INT);", "DROP TABLE b;"}},
+		{"multi line", "CREATE TABLE a (\n    id INT\n);", []string{"CREATE TABLE a (\n    id INT\n);"}},
+		{"comment inside", "CREATE TABLE a (\n-- id dulu\n    id INT\n);", []string{"CREATE TABLE a (\n    id INT\n);"}},
+		{"semicolon mid line", "SELECT ';' AS x, 1;", []string{"SELECT ';' AS x, 1;"}},
+		{"no trailing semicolon", "DROP TABLE a", []string{"DROP TABLE a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statements(tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("statements = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
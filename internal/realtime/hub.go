@@ -0,0 +1,273 @@
+package realtime
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	EventChatCreated = "chat.created"
+	EventChatUpdated = "chat.updated"
+	EventChatDeleted = "chat.deleted"
+	EventChatPurged  = "chat.purged"
+
+	EventReactionAdded   = "reaction.added"
+	EventReactionRemoved = "reaction.removed"
+
+	EventReadUpdated = "read.updated"
+
+	EventMemberAdded  = "member.added"
+	EventMemberKicked = "member.kicked"
+	EventMemberLeft   = "member.left"
+
+	EventRoomCreated = "room.created"
+	EventRoomUpdated = "room.updated"
+	EventRoomDeleted = "room.deleted"
+
+	EventPresenceUpdated = "presence.updated"
+	EventTyping          = "typing"
+
+	// SSE only, the stream couldn't replay everything the client missed, reload rooms and chats over REST
+	EventResync = "resync"
+)
+
+// Event is pushed to every connection subscribed to RoomID.
+// ID is set by the hub from a per-process sequence, every event gets one so it doubles as a replay cursor
+type Event struct {
+	ID     uint64    `json:"id"`
+	Type   string    `json:"type"`
+	RoomID uint      `json:"room_id"`
+	Data   any       `json:"data,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Broadcaster is what the usecases use to notify connected clients
+type Broadcaster interface {
+	Publish(event Event)
+	JoinRoom(roomID uint, userIDs ...uint)
+	LeaveRoom(roomID uint, userIDs ...uint)
+	CloseRoom(roomID uint)
+}
+
+const clientBufferSize = 64
+
+type Client struct {
+	UserID uint
+	send   chan Event
+	rooms  map[uint]struct{}
+	closed bool
+}
+
+// Events is closed when the client is unsubscribed or too slow to keep up
+func (c *Client) Events() <-chan Event {
+	return c.send
+}
+
+type Hub struct {
+	mu       sync.RWMutex
+	rooms    map[uint]map[*Client]struct{}
+	users    map[uint]map[*Client]struct{}
+	presence *Presence
+
+	// id event terakhir, mulai dari waktu start dalam mikrodetik supaya id dari proses
+	// sebelumnya selalu lebih kecil dari startSeq dan ketahuan harus resync
+	seq      uint64
+	startSeq uint64
+	history  *history
+
+	// closed setelah Close, koneksi baru langsung ditutup
+	closed bool
+	// koneksi websocket/SSE yang masih jalan, ditunggu saat shutdown
+	conns sync.WaitGroup
+
+	upgrader *websocket.Upgrader
+}
+
+// NewHub accepts websocket upgrades from browsers on allowedOrigins, see newUpgrader
+func NewHub(allowedOrigins []string) *Hub {
+	start := uint64(time.Now().UnixMicro())
+	return &Hub{
+		upgrader: newUpgrader(allowedOrigins),
+		rooms:    make(map[uint]map[*Client]struct{}),
+		users:    make(map[uint]map[*Client]struct{}),
+		presence: NewPresence(),
+		seq:      start,
+		startSeq: start,
+		history:  newHistory(historySize),
+	}
+}
+
+func (h *Hub) Subscribe(userID uint, roomIDs []uint) *Client {
+	c := &Client{
+		UserID: userID,
+		send:   make(chan Event, clientBufferSize),
+		rooms:  make(map[uint]struct{}),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		c.closed = true
+		close(c.send)
+		return c
+	}
+
+	if h.users[userID] == nil {
+		h.users[userID] = make(map[*Client]struct{})
+	}
+	h.users[userID][c] = struct{}{}
+
+	for _, roomID := range roomIDs {
+		h.addToRoom(c, roomID)
+	}
+
+	if status, changed := h.presence.Touch(userID); changed {
+		h.publishPresenceLocked(userID, status)
+	}
+	return c
+}
+
+func (h *Hub) Unsubscribe(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(c)
+}
+
+// Close ends every connection, websocket clients get a close frame and SSE streams return
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, clients := range h.users {
+		for c := range clients {
+			h.remove(c)
+		}
+	}
+}
+
+// Wait blocks until every connection handler has returned or ctx is done
+func (h *Hub) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.conns.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (h *Hub) Publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.publishLocked(event)
+}
+
+func (h *Hub) publishLocked(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	h.seq++
+	event.ID = h.seq
+	// typing dan presence cuma berlaku saat itu, tidak di-replay
+	if event.Type != EventTyping && event.Type != EventPresenceUpdated {
+		h.history.add(historyEntry{seq: h.seq, roomID: event.RoomID, event: &event})
+	}
+
+	for c := range h.rooms[event.RoomID] {
+		select {
+		case c.send <- event:
+		default:
+			// slow consumer, drop it so one client can't stall the room
+			h.remove(c)
+		}
+	}
+}
+
+// JoinRoom subscribes every open connection of the given users to roomID
+func (h *Hub) JoinRoom(roomID uint, userIDs ...uint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.history.add(historyEntry{seq: h.seq, roomID: roomID, joined: userIDs})
+	for _, userID := range userIDs {
+		for c := range h.users[userID] {
+			h.addToRoom(c, roomID)
+		}
+	}
+}
+
+func (h *Hub) LeaveRoom(roomID uint, userIDs ...uint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.history.add(historyEntry{seq: h.seq, roomID: roomID, left: userIDs})
+	for _, userID := range userIDs {
+		for c := range h.users[userID] {
+			h.removeFromRoom(c, roomID)
+		}
+	}
+}
+
+func (h *Hub) CloseRoom(roomID uint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.history.add(historyEntry{seq: h.seq, roomID: roomID, closed: true})
+	for c := range h.rooms[roomID] {
+		delete(c.rooms, roomID)
+	}
+	delete(h.rooms, roomID)
+}
+
+func (h *Hub) addToRoom(c *Client, roomID uint) {
+	if h.rooms[roomID] == nil {
+		h.rooms[roomID] = make(map[*Client]struct{})
+	}
+	h.rooms[roomID][c] = struct{}{}
+	c.rooms[roomID] = struct{}{}
+}
+
+func (h *Hub) removeFromRoom(c *Client, roomID uint) {
+	delete(c.rooms, roomID)
+	delete(h.rooms[roomID], c)
+	if len(h.rooms[roomID]) == 0 {
+		delete(h.rooms, roomID)
+	}
+}
+
+func (h *Hub) remove(c *Client) {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	close(c.send)
+
+	rooms := make([]uint, 0, len(c.rooms))
+	for roomID := range c.rooms {
+		rooms = append(rooms, roomID)
+		h.removeFromRoom(c, roomID)
+	}
+	delete(h.users[c.UserID], c)
+	if len(h.users[c.UserID]) > 0 {
+		return
+	}
+	delete(h.users, c.UserID)
+
+	// last connection of the user is gone
+	if h.presence.Set(c.UserID, PresenceOffline) {
+		for _, roomID := range rooms {
+			h.publishLocked(presenceEvent(roomID, c.UserID, PresenceOffline))
+		}
+	}
+}
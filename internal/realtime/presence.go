@@ -0,0 +1,291 @@
+package realtime
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	PresenceOnline  = "online"
+	PresenceAway    = "away"
+	PresenceOffline = "offline"
+)
+
+const (
+	// longer than pongWait and sseHeartbeat, a live connection never lets it lapse
+	presenceTTL = 90 * time.Second
+	typingTTL   = 6 * time.Second
+)
+
+type presenceEntry struct {
+	status    string
+	expiresAt time.Time
+	lastSeen  time.Time
+}
+
+// Presence is an in-memory registry of who is online and who is typing where,
+// entries lapse after their TTL and nothing is written to the database
+type Presence struct {
+	mu     sync.Mutex
+	users  map[uint]*presenceEntry
+	typing map[uint]map[uint]time.Time
+}
+
+func NewPresence() *Presence {
+	return &Presence{
+		users:  make(map[uint]*presenceEntry),
+		typing: make(map[uint]map[uint]time.Time),
+	}
+}
+
+// Touch extends the user's TTL, an offline user comes back online while away stays away.
+// The bool reports whether the status changed
+func (p *Presence) Touch(userID uint) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	entry, ok := p.users[userID]
+	if !ok || now.After(entry.expiresAt) || entry.status == PresenceOffline {
+		p.users[userID] = &presenceEntry{status: PresenceOnline, expiresAt: now.Add(presenceTTL), lastSeen: now}
+		return PresenceOnline, true
+	}
+
+	entry.expiresAt = now.Add(presenceTTL)
+	entry.lastSeen = now
+	return entry.status, false
+}
+
+// Set forces a status, used for away and for going offline on the last disconnect
+func (p *Presence) Set(userID uint, status string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	entry, ok := p.users[userID]
+	if !ok || now.After(entry.expiresAt) {
+		entry = &presenceEntry{status: PresenceOffline}
+		p.users[userID] = entry
+	}
+
+	changed := entry.status != status
+	entry.status = status
+	entry.lastSeen = now
+	entry.expiresAt = now.Add(presenceTTL)
+	if status == PresenceOffline {
+		p.clearTyping(userID)
+	}
+	return changed
+}
+
+// Status returns offline for unknown or lapsed users, lastSeen is zero if never seen
+func (p *Presence) Status(userID uint) (string, time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.users[userID]
+	if !ok {
+		return PresenceOffline, time.Time{}
+	}
+	if time.Now().After(entry.expiresAt) {
+		return PresenceOffline, entry.lastSeen
+	}
+	return entry.status, entry.lastSeen
+}
+
+// SetTyping reports whether the typing state of the user in the room changed
+func (p *Presence) SetTyping(roomID, userID uint, typing bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, was := p.typing[roomID][userID]
+	if !typing {
+		delete(p.typing[roomID], userID)
+		if len(p.typing[roomID]) == 0 {
+			delete(p.typing, roomID)
+		}
+		return was
+	}
+
+	if p.typing[roomID] == nil {
+		p.typing[roomID] = make(map[uint]time.Time)
+	}
+	p.typing[roomID][userID] = time.Now().Add(typingTTL)
+	return !was
+}
+
+func (p *Presence) IsTyping(roomID, userID uint) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	expiresAt, ok := p.typing[roomID][userID]
+	return ok && time.Now().Before(expiresAt)
+}
+
+type typingKey struct {
+	RoomID uint
+	UserID uint
+}
+
+// Sweep drops lapsed entries and returns the users that went offline and the
+// typing indicators that ran out, so the hub can tell the rooms
+func (p *Presence) Sweep() ([]uint, []typingKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var offline []uint
+	for userID, entry := range p.users {
+		if now.Before(entry.expiresAt) {
+			continue
+		}
+		if entry.status != PresenceOffline {
+			offline = append(offline, userID)
+		}
+		delete(p.users, userID)
+	}
+
+	var stopped []typingKey
+	for roomID, users := range p.typing {
+		for userID, expiresAt := range users {
+			if now.After(expiresAt) {
+				stopped = append(stopped, typingKey{roomID, userID})
+				delete(users, userID)
+			}
+		}
+		if len(users) == 0 {
+			delete(p.typing, roomID)
+		}
+	}
+	return offline, stopped
+}
+
+func (p *Presence) clearTyping(userID uint) {
+	for roomID, users := range p.typing {
+		delete(users, userID)
+		if len(users) == 0 {
+			delete(p.typing, roomID)
+		}
+	}
+}
+
+type PresenceData struct {
+	UserID uint   `json:"user_id"`
+	Status string `json:"status"`
+}
+
+type TypingData struct {
+	UserID uint `json:"user_id"`
+	Typing bool `json:"typing"`
+}
+
+func presenceEvent(roomID, userID uint, status string) Event {
+	return Event{Type: EventPresenceUpdated, RoomID: roomID, Data: PresenceData{userID, status}}
+}
+
+func typingEvent(roomID, userID uint, typing bool) Event {
+	return Event{Type: EventTyping, RoomID: roomID, Data: TypingData{userID, typing}}
+}
+
+// Touch marks the user as still around, called on pongs, inbound frames and sse heartbeats
+func (h *Hub) Touch(userID uint) {
+	status, changed := h.presence.Touch(userID)
+	if !changed {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.publishPresenceLocked(userID, status)
+}
+
+// SetStatus lets a connected user switch between online and away,
+// it returns false for any other status or when the user has no open connection
+func (h *Hub) SetStatus(userID uint, status string) bool {
+	if status != PresenceOnline && status != PresenceAway {
+		return false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.users[userID]) == 0 {
+		return false
+	}
+	if h.presence.Set(userID, status) {
+		h.publishPresenceLocked(userID, status)
+	}
+	return true
+}
+
+// SetTyping only accepts users that have a connection subscribed to the room,
+// the indicator stops on its own after typingTTL unless it is sent again
+func (h *Hub) SetTyping(roomID, userID uint, typing bool) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.inRoomLocked(roomID, userID) {
+		return false
+	}
+	if h.presence.SetTyping(roomID, userID, typing) {
+		h.publishLocked(typingEvent(roomID, userID, typing))
+	}
+	return true
+}
+
+// PresenceOf returns the status, last seen time and whether the user is typing in roomID
+func (h *Hub) PresenceOf(roomID, userID uint) (string, time.Time, bool) {
+	status, lastSeen := h.presence.Status(userID)
+	return status, lastSeen, h.presence.IsTyping(roomID, userID)
+}
+
+// Run expires presence and typing entries until ctx is done
+func (h *Hub) Run(ctx context.Context) {
+	ticker := time.NewTicker(typingTTL / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		offline, stopped := h.presence.Sweep()
+		if len(offline) == 0 && len(stopped) == 0 {
+			continue
+		}
+
+		h.mu.Lock()
+		for _, userID := range offline {
+			h.publishPresenceLocked(userID, PresenceOffline)
+		}
+		for _, key := range stopped {
+			h.publishLocked(typingEvent(key.RoomID, key.UserID, false))
+		}
+		h.mu.Unlock()
+	}
+}
+
+// publishPresenceLocked tells every room the user's connections are subscribed to
+func (h *Hub) publishPresenceLocked(userID uint, status string) {
+	rooms := make(map[uint]struct{})
+	for c := range h.users[userID] {
+		for roomID := range c.rooms {
+			rooms[roomID] = struct{}{}
+		}
+	}
+	for roomID := range rooms {
+		h.publishLocked(presenceEvent(roomID, userID, status))
+	}
+}
+
+func (h *Hub) inRoomLocked(roomID, userID uint) bool {
+	for c := range h.users[userID] {
+		if _, ok := c.rooms[roomID]; ok {
+			return true
+		}
+	}
+	return false
+}
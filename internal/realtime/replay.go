@@ -0,0 +1,107 @@
+package realtime
+
+import "slices"
+
+// event terakhir yang disimpan untuk replay SSE, lebih lama dari ini client harus resync
+const historySize = 4096
+
+// historyEntry is either a published event or a membership change, the latter are kept
+// so replay knows which rooms a user could see at the time of each event
+type historyEntry struct {
+	seq    uint64
+	roomID uint
+	event  *Event
+
+	joined []uint
+	left   []uint
+	closed bool
+}
+
+// history is a ring buffer of the newest entries, guarded by the hub mutex
+type history struct {
+	entries []historyEntry
+	next    int
+	full    bool
+	// seq entry terbaru yang sudah terbuang dari buffer
+	evicted uint64
+}
+
+func newHistory(size int) *history {
+	return &history{entries: make([]historyEntry, size)}
+}
+
+func (h *history) add(entry historyEntry) {
+	if h.full {
+		h.evicted = h.entries[h.next].seq
+	}
+	h.entries[h.next] = entry
+	h.next++
+	if h.next == len(h.entries) {
+		h.next = 0
+		h.full = true
+	}
+}
+
+// newestFirst walks the buffer from the latest entry back, stopping when fn returns false
+func (h *history) newestFirst(fn func(entry historyEntry) bool) {
+	n := h.next
+	if h.full {
+		n = len(h.entries)
+	}
+	for i := 1; i <= n; i++ {
+		if !fn(h.entries[(h.next-i+len(h.entries))%len(h.entries)]) {
+			return
+		}
+	}
+}
+
+// Replay returns the events after lastEventID that userID, a member of roomIDs now, would have
+// received, oldest first. upTo is the newest event id at that moment, a stream subscribed before
+// calling Replay skips live events up to it. When the gap can't be rebuilt (another process,
+// buffer overflow or a room that was deleted meanwhile) the result is a single resync event
+func (h *Hub) Replay(userID uint, roomIDs []uint, lastEventID uint64) (events []Event, upTo uint64) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	upTo = h.seq
+	resync := []Event{{ID: upTo, Type: EventResync, Data: map[string]any{"last_event_id": lastEventID}}}
+	if lastEventID < h.startSeq || lastEventID > h.seq || lastEventID < h.history.evicted {
+		return resync, upTo
+	}
+
+	// jalan mundur dari keanggotaan sekarang, sebelum join event room itu belum kelihatan,
+	// sebelum leave/kick masih kelihatan
+	member := make(map[uint]bool, len(roomIDs))
+	for _, roomID := range roomIDs {
+		member[roomID] = true
+	}
+	complete := true
+	h.history.newestFirst(func(entry historyEntry) bool {
+		switch {
+		case entry.event != nil:
+			if entry.seq <= lastEventID {
+				return false
+			}
+			if member[entry.roomID] {
+				events = append(events, *entry.event)
+			}
+		case entry.seq < lastEventID:
+			return false
+		case entry.closed:
+			// anggota room yang offline tidak diketahui hub
+			complete = false
+			return false
+		case slices.Contains(entry.joined, userID):
+			member[entry.roomID] = false
+		case slices.Contains(entry.left, userID):
+			member[entry.roomID] = true
+		}
+		return true
+	})
+	if !complete {
+		return resync, upTo
+	}
+
+	slices.Reverse(events)
+	return events, upTo
+}
@@ -0,0 +1,97 @@
+package realtime
+
+import (
+	"chat/utils/metrics"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const sseHeartbeat = 30 * time.Second
+
+// ServeSSE streams room events as text/event-stream until the client goes away.
+// lastEventID is nil on a first connect, otherwise the events after it are replayed from the
+// hub history (see Replay). The client is subscribed before replay runs so nothing published
+// in between is lost, live events that were already replayed are skipped.
+//
+// Every event carries an "id:" line, so the client's Last-Event-ID always points at the newest
+// event it has seen. Ids restart from a higher value in a new process, a client coming back with
+// an id from the previous one gets a resync event.
+func (h *Hub) ServeSSE(w http.ResponseWriter, r *http.Request, userID uint, roomIDs []uint, lastEventID *uint64) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return errors.New("streaming unsupported")
+	}
+
+	h.conns.Add(1)
+	defer h.conns.Done()
+	defer metrics.ConnectionOpened(metrics.TransportSSE)()
+
+	// stream jalan terus, jangan sampai diputus WriteTimeout server
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		return err
+	}
+
+	client := h.Subscribe(userID, roomIDs)
+	defer h.Unsubscribe(client)
+
+	var missed []Event
+	var lastID uint64
+	if lastEventID != nil {
+		missed, lastID = h.Replay(userID, roomIDs, *lastEventID)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+
+	for _, event := range missed {
+		if err := writeSSE(w, event); err != nil {
+			return nil
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case event, ok := <-client.Events():
+			if !ok {
+				return nil
+			}
+			if event.ID <= lastID {
+				continue
+			}
+			if err := writeSSE(w, event); err != nil {
+				return nil
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			h.Touch(userID)
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return nil
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
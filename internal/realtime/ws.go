@@ -0,0 +1,136 @@
+package realtime
+
+import (
+	"chat/utils/metrics"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait  = 10 * time.Second
+	pongWait   = 60 * time.Second
+	pingPeriod = (pongWait * 9) / 10
+	maxMessage = 4096
+)
+
+// newUpgrader lets browsers upgrade only from allowedOrigins or the API's own origin. Browsers
+// always send Origin on a websocket handshake, a request without one comes from a non-browser
+// client (mobile app, CLI) that holds its own token
+func newUpgrader(allowedOrigins []string) *websocket.Upgrader {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		if u, err := url.Parse(origin); err == nil && u.Host != "" {
+			allowed[strings.ToLower(u.Scheme+"://"+u.Host)] = true
+		}
+	}
+
+	return &websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				return true
+			}
+			u, err := url.Parse(origin)
+			if err != nil {
+				return false
+			}
+			return strings.EqualFold(u.Host, r.Host) || allowed[strings.ToLower(u.Scheme+"://"+u.Host)]
+		},
+	}
+}
+
+// clientMessage is the only thing a client sends, typing and presence signals:
+//
+//	{"type": "typing", "room_id": 1, "typing": true}
+//	{"type": "presence", "status": "away"}
+type clientMessage struct {
+	Type   string `json:"type"`
+	RoomID uint   `json:"room_id"`
+	Typing bool   `json:"typing"`
+	Status string `json:"status"`
+}
+
+// ServeWS upgrades the request and streams room events to it until either side closes
+func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request, userID uint, roomIDs []uint) error {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return err
+	}
+
+	h.conns.Add(1)
+	defer h.conns.Done()
+	defer metrics.ConnectionOpened(metrics.TransportWebSocket)()
+
+	client := h.Subscribe(userID, roomIDs)
+	go h.writePump(conn, client)
+	h.readPump(conn, client)
+	return nil
+}
+
+func (h *Hub) readPump(conn *websocket.Conn, client *Client) {
+	defer func() {
+		h.Unsubscribe(client)
+		conn.Close()
+	}()
+
+	conn.SetReadLimit(maxMessage)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		h.Touch(client.UserID)
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		h.Touch(client.UserID)
+
+		// anything that isn't a known signal is ignored
+		var msg clientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		switch msg.Type {
+		case "typing":
+			h.SetTyping(msg.RoomID, client.UserID, msg.Typing)
+		case "presence":
+			h.SetStatus(client.UserID, msg.Status)
+		}
+	}
+}
+
+func (h *Hub) writePump(conn *websocket.Conn, client *Client) {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+
+	for {
+		select {
+		case event, ok := <-client.Events():
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
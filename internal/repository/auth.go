@@ -3,6 +3,8 @@ package repository
 import (
 	"chat/model"
 	"errors"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -15,6 +17,22 @@ type UserRepository interface {
 	ValidateUser(email string) error
 	IsValidate(email string) (bool, error)
 	IsUserExist(email string) (bool, error)
+	UpdatePassword(userID uint, hashedPassword string) error
+	SetTOTPSecret(userID uint, secret string) error
+	EnableTOTP(userID uint, recoveryCodes string) error
+	DisableTOTP(userID uint) error
+	UseTOTPStep(userID uint, step int64) (bool, error)
+	ReplaceRecoveryCodes(userID uint, oldCodes, newCodes string) (bool, error)
+	RecordMFAFailure(userID uint, limit int) (bool, error)
+	ResetMFAFailures(userID uint) error
+}
+
+// email selalu disimpan lowercase (lihat normalizeEmail) supaya cocok dan unique index-nya
+// berlaku sama di mysql, postgres dan sqlite tanpa LOWER() yang bikin index tidak terpakai
+const emailMatch = "email = ?"
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
 
 type userRepository struct {
@@ -28,7 +46,7 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 func (r *userRepository) CreateUser(username, email, hashedpassword string) error {
 	newUser := model.User{
 		Username: username,
-		Email:    email,
+		Email:    normalizeEmail(email),
 		Password: hashedpassword,
 	}
 
@@ -42,7 +60,7 @@ func (r *userRepository) CreateUser(username, email, hashedpassword string) erro
 
 func (r *userRepository) Login(email string) (*model.User, error) {
 	var user model.User
-	if err := r.db.Limit(1).Where("email = ?", email).First(&user).Error; err != nil {
+	if err := r.db.Limit(1).Where(emailMatch, normalizeEmail(email)).First(&user).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
@@ -50,10 +68,10 @@ func (r *userRepository) Login(email string) (*model.User, error) {
 
 func (r *userRepository) UpdateUser(firstEmail, username, email string) error {
 	user := model.User{
-		Email:    email,
+		Email:    normalizeEmail(email),
 		Username: username,
 	}
-	if err := r.db.Model(&model.User{}).Where("email = ?", firstEmail).Updates(&user).Error; err != nil {
+	if err := r.db.Model(&model.User{}).Where(emailMatch, normalizeEmail(firstEmail)).Updates(&user).Error; err != nil {
 		return err
 	}
 	return nil
@@ -63,12 +81,27 @@ func (r *userRepository) DeleteUser(email string) error {
 	tx := r.db.Begin()
 
 	var user model.User
-	if err := tx.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := tx.Where(emailMatch, normalizeEmail(email)).First(&user).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Where("user_id = ?", user.ID).Delete(&model.Mention{}).Error; err != nil {
 		tx.Rollback()
 		return err
 	}
 
-	if err := tx.Where("sender_id", user.ID).Delete(&model.Chat{}).Error; err != nil {
+	if err := tx.Where("user_id = ?", user.ID).Delete(&model.Reaction{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// chat-nya cuma di-tombstone, hard delete ikut membuang balasan user lain di thread-nya.
+	// sender_id jadi NULL lewat FK saat user dihapus, retention job yang membuang tombstone-nya nanti
+	if err := tx.Model(&model.Chat{}).Where("sender_id = ? AND deleted_at IS NULL", user.ID).Updates(map[string]any{
+		"deleted_at": time.Now(),
+		"deleted_by": user.ID,
+	}).Error; err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -83,6 +116,29 @@ func (r *userRepository) DeleteUser(email string) error {
 		return err
 	}
 
+	if err := tx.Where("user_id = ?", user.ID).Delete(&model.PasswordReset{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var sessionIDs []string
+	if err := tx.Model(&model.Session{}).Where("user_id = ?", user.ID).Pluck("id", &sessionIDs).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if len(sessionIDs) > 0 {
+		if err := tx.Where("session_id IN ?", sessionIDs).Delete(&model.RefreshToken{}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Where("user_id = ?", user.ID).Delete(&model.Session{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	if err := tx.Where("id = ?", user.ID).Delete(&model.User{}).Error; err != nil {
 		tx.Rollback()
 		return err
@@ -94,7 +150,7 @@ func (r *userRepository) DeleteUser(email string) error {
 
 func (r *userRepository) ValidateUser(email string) error {
 	result := r.db.Model(&model.User{}).
-		Where("email = ?", email).
+		Where(emailMatch+" AND is_verified = ?", normalizeEmail(email), false).
 		Update("is_verified", true) // Bisa pakai Update tunggal
 
 	if result.Error != nil {
@@ -108,18 +164,18 @@ func (r *userRepository) ValidateUser(email string) error {
 }
 
 func (r *userRepository) IsValidate(email string) (bool, error) {
-	err := r.db.Model(&model.User{}).Where("email = ? AND is_verified", email, true)
-	if err != nil {
-		return false, err.Error
+	var count int64
+	if err := r.db.Model(&model.User{}).Where(emailMatch+" AND is_verified = ?", normalizeEmail(email), true).Count(&count).Error; err != nil {
+		return false, err
 	}
-	return true, nil
+	return count > 0, nil
 }
 
 func (r *userRepository) IsUserExist(email string) (bool, error) {
 	var user model.User
 
 	// Cek apakah ada user dengan email tersebut
-	err := r.db.Model(&model.User{}).Where("email = ?", email).First(&user).Error
+	err := r.db.Model(&model.User{}).Where(emailMatch, normalizeEmail(email)).First(&user).Error
 
 	if err != nil {
 		// Jika error karena tidak ditemukan, berarti user tidak ada
@@ -133,3 +189,74 @@ func (r *userRepository) IsUserExist(email string) (bool, error) {
 	// Jika tidak error, berarti user ditemukan
 	return true, nil
 }
+
+func (r *userRepository) UpdatePassword(userID uint, hashedPassword string) error {
+	return r.db.Model(&model.User{}).Where("id = ?", userID).Update("password", hashedPassword).Error
+}
+
+// SetTOTPSecret stores a pending secret, it only counts once EnableTOTP is called
+func (r *userRepository) SetTOTPSecret(userID uint, secret string) error {
+	return r.db.Model(&model.User{}).Where("id = ? AND totp_enabled = ?", userID, false).Update("totp_secret", secret).Error
+}
+
+func (r *userRepository) EnableTOTP(userID uint, recoveryCodes string) error {
+	return r.db.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]any{
+		"totp_enabled":   true,
+		"recovery_codes": recoveryCodes,
+	}).Error
+}
+
+func (r *userRepository) DisableTOTP(userID uint) error {
+	return r.db.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]any{
+		"totp_secret":    nil,
+		"totp_enabled":   false,
+		"totp_last_step": 0,
+		"recovery_codes": "",
+	}).Error
+}
+
+// UseTOTPStep only moves forward, false means the code (or a newer one) was already used
+func (r *userRepository) UseTOTPStep(userID uint, step int64) (bool, error) {
+	result := r.db.Model(&model.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ReplaceRecoveryCodes is a compare-and-swap so one recovery code can't be used twice concurrently
+func (r *userRepository) ReplaceRecoveryCodes(userID uint, oldCodes, newCodes string) (bool, error) {
+	result := r.db.Model(&model.User{}).
+		Where("id = ? AND recovery_codes = ?", userID, oldCodes).
+		Update("recovery_codes", newCodes)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// RecordMFAFailure counts one wrong login code, true means this one hit the limit and the user got locked
+func (r *userRepository) RecordMFAFailure(userID uint, limit int) (bool, error) {
+	if err := r.db.Model(&model.User{}).Where("id = ?", userID).
+		Update("mfa_failures", gorm.Expr("mfa_failures + 1")).Error; err != nil {
+		return false, err
+	}
+
+	// conditional supaya dua request yang sama-sama mencapai limit tidak dua kali mengunci
+	result := r.db.Model(&model.User{}).
+		Where("id = ? AND mfa_failures >= ?", userID, limit).
+		Updates(map[string]any{
+			"mfa_failures":  0,
+			"mfa_locked_at": time.Now(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *userRepository) ResetMFAFailures(userID uint) error {
+	return r.db.Model(&model.User{}).Where("id = ? AND mfa_failures > 0", userID).Update("mfa_failures", 0).Error
+}
@@ -3,17 +3,37 @@ package repository
 import (
 	"chat/model"
 	"chat/response"
+	"chat/utils"
 	"errors"
+	"slices"
 	"time"
 
 	"gorm.io/gorm"
 )
 
 type ChatRepository interface {
-	GetAllChatByRoomID(roomID uint) ([]response.ChatResponse, error)
-	CreateChat(message string, roomID, userID uint) (*response.ChatResponse, error)
-	DeleteChat(roomID, userID uint) error
-	UpdateChat(roomID, userID uint, message string) error
+	GetAllChatByRoomID(roomID uint, cursor ChatCursor) (*response.ChatPageResponse, error)
+	GetReplies(roomID, parentID uint, cursor ChatCursor) (*response.ChatPageResponse, error)
+	CreateChat(message string, roomID, userID uint, parentID *uint, mentions []model.Mention) (*model.Chat, error)
+	GetChatByID(roomID, chatID uint) (*model.Chat, error)
+	GetChatResponse(chatID uint) (*response.ChatResponse, error)
+	DeleteChat(chatID, deletedBy uint) error
+	PurgeChat(chatID uint) ([]uint, error)
+	PurgeDeletedBefore(before time.Time) (int64, error)
+	UpdateChat(chatID uint, message string, mentions []model.Mention) (*model.Chat, error)
+	GetChatRevisions(chatID uint) ([]response.ChatRevisionResponse, error)
+}
+
+const chatColumns = "id,room_id,sender_id,parent_id,message,created_at AS time,edited_at,deleted_at"
+
+// TombstoneMessage replaces the text of a soft deleted chat in every listing
+const TombstoneMessage = "message deleted"
+
+// ChatCursor is a keyset position on chats.id, at most one of Before/After is set
+type ChatCursor struct {
+	Before uint
+	After  uint
+	Limit  int
 }
 
 type chatRepository struct {
@@ -24,42 +44,200 @@ func NewChatRepository(db *gorm.DB) ChatRepository {
 	return &chatRepository{db}
 }
 
-func (r *chatRepository) GetAllChatByRoomID(roomID uint) ([]response.ChatResponse, error) {
-	var response []response.ChatResponse
-	err := r.db.Table("chats").
-		Select("room_id,sender_id,message,created_at").
-		Where("room_id = ?", roomID).
-		Order("created_at ASC").
-		Find(&response).Error
+// GetAllChatByRoomID lists the root messages of a room, replies only show up as thread summaries
+func (r *chatRepository) GetAllChatByRoomID(roomID uint, cursor ChatCursor) (*response.ChatPageResponse, error) {
+	page, err := r.getPage(cursor, func() *gorm.DB {
+		return r.db.Table("chats").Where("room_id = ? AND parent_id IS NULL", roomID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.fillThreadSummaries(page.Chats); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (r *chatRepository) GetReplies(roomID, parentID uint, cursor ChatCursor) (*response.ChatPageResponse, error) {
+	return r.getPage(cursor, func() *gorm.DB {
+		return r.db.Table("chats").Where("room_id = ? AND parent_id = ?", roomID, parentID)
+	})
+}
+
+// getPage runs a keyset query over scope, which must return a fresh query each call
+func (r *chatRepository) getPage(cursor ChatCursor, scope func() *gorm.DB) (*response.ChatPageResponse, error) {
+	query := scope().Select(chatColumns)
 
+	// fetch one extra row to know whether there is another page in that direction
+	chats := []response.ChatResponse{}
+	var err error
+	if cursor.After != 0 {
+		err = query.Where("id > ?", cursor.After).Order("id ASC").Limit(cursor.Limit + 1).Find(&chats).Error
+	} else {
+		if cursor.Before != 0 {
+			query = query.Where("id < ?", cursor.Before)
+		}
+		err = query.Order("id DESC").Limit(cursor.Limit + 1).Find(&chats).Error
+	}
 	if err != nil {
 		return nil, err
 	}
-	return response, nil
+
+	hasMore := len(chats) > cursor.Limit
+	if hasMore {
+		chats = chats[:cursor.Limit]
+	}
+	if cursor.After == 0 {
+		slices.Reverse(chats)
+	}
+	hideDeleted(chats)
+
+	page := &response.ChatPageResponse{Chats: chats}
+	if len(chats) == 0 {
+		return page, nil
+	}
+
+	oldest, newest := chats[0].ID, chats[len(chats)-1].ID
+	hasOlder, hasNewer := hasMore, hasMore
+	if cursor.After != 0 {
+		hasOlder, err = hasChat(scope(), "id < ?", oldest)
+	} else {
+		hasNewer, err = hasChat(scope(), "id > ?", newest)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if hasOlder {
+		page.NextCursor = &oldest
+	}
+	if hasNewer {
+		page.PrevCursor = &newest
+	}
+	return page, nil
+}
+
+func hasChat(query *gorm.DB, cond string, id uint) (bool, error) {
+	var ids []uint
+	err := query.Where(cond, id).Limit(1).Pluck("id", &ids).Error
+	return len(ids) > 0, err
+}
+
+func (r *chatRepository) fillThreadSummaries(chats []response.ChatResponse) error {
+	if len(chats) == 0 {
+		return nil
+	}
+
+	rootIDs := make([]uint, 0, len(chats))
+	for _, chat := range chats {
+		rootIDs = append(rootIDs, chat.ID)
+	}
+
+	var summaries []struct {
+		ParentID    uint
+		ReplyCount  int64
+		LastReplyID uint
+	}
+	err := r.db.Table("chats").
+		Select("parent_id, COUNT(*) AS reply_count, MAX(id) AS last_reply_id").
+		Where("parent_id IN ? AND deleted_at IS NULL", rootIDs).
+		Group("parent_id").
+		Scan(&summaries).Error
+	if err != nil || len(summaries) == 0 {
+		return err
+	}
+
+	lastReplyIDs := make([]uint, 0, len(summaries))
+	for _, summary := range summaries {
+		lastReplyIDs = append(lastReplyIDs, summary.LastReplyID)
+	}
+
+	var lastReplies []model.Chat
+	if err := r.db.Select("id, created_at").Where("id IN ?", lastReplyIDs).Find(&lastReplies).Error; err != nil {
+		return err
+	}
+	lastReplyAt := make(map[uint]time.Time, len(lastReplies))
+	for _, reply := range lastReplies {
+		lastReplyAt[reply.ID] = reply.CreatedAt
+	}
+
+	byRoot := make(map[uint]int, len(chats))
+	for i := range chats {
+		byRoot[chats[i].ID] = i
+	}
+	for _, summary := range summaries {
+		chat := &chats[byRoot[summary.ParentID]]
+		chat.ReplyCount = summary.ReplyCount
+		if at, ok := lastReplyAt[summary.LastReplyID]; ok {
+			chat.LastReplyAt = &at
+		}
+	}
+	return nil
+}
+
+func hideDeleted(chats []response.ChatResponse) {
+	for i := range chats {
+		if chats[i].DeletedAt != nil {
+			chats[i].Message = TombstoneMessage
+		}
+	}
 }
 
-func (r *chatRepository) CreateChat(message string, roomID, userID uint) (*response.ChatResponse, error) {
+// CreateChat stores the chat and its mentions together, a failed mention insert leaves no chat behind
+func (r *chatRepository) CreateChat(message string, roomID, userID uint, parentID *uint, mentions []model.Mention) (*model.Chat, error) {
 	chat := &model.Chat{
 		RoomID:    roomID,
 		SenderID:  &userID,
+		ParentID:  parentID,
 		Message:   message,
 		CreatedAt: time.Now(),
 	}
 
-	if err := r.db.Create(chat).Error; err != nil {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(chat).Error; err != nil {
+			return err
+		}
+		return replaceMentions(tx, chat.ID, mentions)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return chat, nil
+}
+
+func (r *chatRepository) GetChatByID(roomID, chatID uint) (*model.Chat, error) {
+	var chat model.Chat
+	if err := r.db.Where("id = ? AND room_id = ?", chatID, roomID).First(&chat).Error; err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+// GetChatResponse reads one chat the way the listings do, thread summary included for a root
+func (r *chatRepository) GetChatResponse(chatID uint) (*response.ChatResponse, error) {
+	chats := []response.ChatResponse{}
+	if err := r.db.Table("chats").Select(chatColumns).Where("id = ?", chatID).Find(&chats).Error; err != nil {
 		return nil, err
 	}
-	response := &response.ChatResponse{
-		RoomID:   roomID,
-		SenderID: userID,
-		Message:  message,
-		Time:     time.Now(),
+	if len(chats) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	hideDeleted(chats)
+
+	if chats[0].ParentID == nil {
+		if err := r.fillThreadSummaries(chats); err != nil {
+			return nil, err
+		}
 	}
-	return response, nil
+	return &chats[0], nil
 }
 
-func (r *chatRepository) DeleteChat(roomID, userID uint) error {
-	result := r.db.Model(&model.Chat{}).Where("room_id = ? AND sender_id = ?", roomID, userID).Delete(&model.Chat{})
+func (r *chatRepository) DeleteChat(chatID, deletedBy uint) error {
+	result := r.db.Model(&model.Chat{}).Where("id = ? AND deleted_at IS NULL", chatID).Updates(map[string]interface{}{
+		"deleted_at": time.Now(),
+		"deleted_by": deletedBy,
+	})
 	if result.Error != nil {
 		return result.Error
 	}
@@ -69,15 +247,110 @@ func (r *chatRepository) DeleteChat(roomID, userID uint) error {
 	return nil
 }
 
-func (r *chatRepository) UpdateChat(roomID, userID uint, message string) error {
-	result := r.db.Model(&model.Chat{}).Where("room_id = ? AND sender_id = ?", roomID, userID).Updates(map[string]interface{}{
-		"message": message,
+// PurgeChat removes the row for good, revisions go with it through the cascade.
+// Like PurgeDeletedBefore it refuses a root with live replies, deleted replies cascade
+// away with the root and their ids are returned so clients can drop them too
+func (r *chatRepository) PurgeChat(chatID uint) ([]uint, error) {
+	replyIDs := []uint{}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var live int64
+		if err := tx.Model(&model.Chat{}).Where("parent_id = ? AND deleted_at IS NULL", chatID).Count(&live).Error; err != nil {
+			return err
+		}
+		if live > 0 {
+			return utils.ErrHasReplies
+		}
+
+		if err := tx.Model(&model.Chat{}).Where("parent_id = ?", chatID).Pluck("id", &replyIDs).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("id = ?", chatID).Delete(&model.Chat{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return utils.ErrChatNotFound
+		}
+		return nil
 	})
-	if result.Error != nil {
-		return result.Error
+	if err != nil {
+		return nil, err
 	}
-	if result.RowsAffected == 0 {
-		return errors.New("no chat found to update")
+	return replyIDs, nil
+}
+
+// PurgeDeletedBefore skips tombstones that still root a thread with live replies,
+// purging them would cascade the replies away
+func (r *chatRepository) PurgeDeletedBefore(before time.Time) (int64, error) {
+	var ids []uint
+	err := r.db.Model(&model.Chat{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+		Where("NOT EXISTS (SELECT 1 FROM chats replies WHERE replies.parent_id = chats.id AND replies.deleted_at IS NULL)").
+		Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return 0, err
 	}
-	return nil
+
+	result := r.db.Where("id IN ?", ids).Delete(&model.Chat{})
+	return result.RowsAffected, result.Error
+}
+
+// UpdateChat keeps the current text as a ChatRevision before overwriting it, the mentions
+// are re-resolved from the new text and replaced in the same transaction
+func (r *chatRepository) UpdateChat(chatID uint, message string, mentions []model.Mention) (*model.Chat, error) {
+	var chat model.Chat
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", chatID).First(&chat).Error; err != nil {
+			return err
+		}
+
+		writtenAt := chat.CreatedAt
+		if chat.EditedAt != nil {
+			writtenAt = *chat.EditedAt
+		}
+
+		revision := &model.ChatRevision{
+			ChatID:    chat.ID,
+			Message:   chat.Message,
+			WrittenAt: writtenAt,
+		}
+		if err := tx.Create(revision).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		result := tx.Model(&model.Chat{}).Where("id = ?", chatID).Updates(map[string]interface{}{
+			"message":   message,
+			"edited_at": now,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("no chat found to update")
+		}
+
+		chat.Message = message
+		chat.EditedAt = &now
+		return replaceMentions(tx, chatID, mentions)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+func (r *chatRepository) GetChatRevisions(chatID uint) ([]response.ChatRevisionResponse, error) {
+	revisions := []response.ChatRevisionResponse{}
+	err := r.db.Table("chat_revisions").
+		Select("id,chat_id,message,written_at,created_at AS replaced_at").
+		Where("chat_id = ?", chatID).
+		Order("id ASC").
+		Find(&revisions).Error
+
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
 }
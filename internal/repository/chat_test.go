@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"chat/internal/migration"
+	"chat/migrations"
+	"chat/model"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_pragma=foreign_keys(1)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	migs, err := migration.Load(migrations.FS, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migration.NewMigrator(db, migs).Up(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// seedRoom creates a room with n root messages, ids in insert order
+func seedRoom(t *testing.T, db *gorm.DB, n int) (*model.RoomChat, []uint) {
+	t.Helper()
+
+	user := &model.User{Username: "budi", Email: "budi@example.com", Password: "x"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	room := &model.RoomChat{Name: "umum", Type: model.RoomTypeGroup, CreatorID: user.ID}
+	if err := db.Create(room).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]uint, 0, n)
+	for i := range n {
+		chat := &model.Chat{RoomID: room.ID, SenderID: &user.ID, Message: fmt.Sprint("pesan ", i+1), CreatedAt: time.Now()}
+		if err := db.Create(chat).Error; err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, chat.ID)
+	}
+	return room, ids
+}
+
+func TestGetAllChatByRoomID_Pagination(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewChatRepository(db)
+	room, ids := seedRoom(t, db, 5)
+
+	// satu balasan tidak ikut terhitung di halaman room
+	reply := &model.Chat{RoomID: room.ID, ParentID: &ids[4], Message: "balasan", CreatedAt: time.Now()}
+	if err := db.Create(reply).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ptr := func(id uint) *uint { return &id }
+	tests := []struct {
+		name   string
+		cursor ChatCursor
+		want   []uint
+		next   *uint
+		prev   *uint
+	}{
+		{"newest page", ChatCursor{Limit: 2}, ids[3:5], ptr(ids[3]), nil},
+		{"before", ChatCursor{Before: ids[3], Limit: 2}, ids[1:3], ptr(ids[1]), ptr(ids[2])},
+		{"last page", ChatCursor{Before: ids[1], Limit: 2}, ids[0:1], nil, ptr(ids[0])},
+		{"after", ChatCursor{After: ids[0], Limit: 2}, ids[1:3], ptr(ids[1]), ptr(ids[2])},
+		{"after reaches newest", ChatCursor{After: ids[2], Limit: 2}, ids[3:5], ptr(ids[3]), nil},
+		{"everything", ChatCursor{Limit: 10}, ids, nil, nil},
+		{"after newest", ChatCursor{After: ids[4], Limit: 2}, []uint{}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := repo.GetAllChatByRoomID(room.ID, tt.cursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]uint, 0, len(page.Chats))
+			for _, chat := range page.Chats {
+				got = append(got, chat.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ids = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(page.NextCursor, tt.next) {
+				t.Errorf("next_cursor = %v, want %v", deref(page.NextCursor), deref(tt.next))
+			}
+			if !reflect.DeepEqual(page.PrevCursor, tt.prev) {
+				t.Errorf("prev_cursor = %v, want %v", deref(page.PrevCursor), deref(tt.prev))
+			}
+		})
+	}
+
+	page, err := repo.GetAllChatByRoomID(room.ID, ChatCursor{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Chats[0].ReplyCount != 1 || page.Chats[0].LastReplyAt == nil {
+		t.Errorf("thread summary = %d replies, last at %v", page.Chats[0].ReplyCount, page.Chats[0].LastReplyAt)
+	}
+}
+
+func TestGetAllChatByRoomID_HidesDeleted(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewChatRepository(db)
+	room, ids := seedRoom(t, db, 2)
+
+	if err := repo.DeleteChat(ids[0], 1); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := repo.GetAllChatByRoomID(room.ID, ChatCursor{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Chats) != 2 {
+		t.Fatalf("got %d chats, tombstones stay in the listing", len(page.Chats))
+	}
+	if page.Chats[0].Message != TombstoneMessage || page.Chats[1].Message != "pesan 2" {
+		t.Errorf("messages = %q, %q", page.Chats[0].Message, page.Chats[1].Message)
+	}
+}
+
+func deref(id *uint) any {
+	if id == nil {
+		return nil
+	}
+	return *id
+}
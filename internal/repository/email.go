@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"chat/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type EmailRepository interface {
+	Enqueue(email *model.OutboundEmail) error
+	ClaimDue(limit int, lease time.Duration) ([]model.OutboundEmail, error)
+	MarkSent(id uint) error
+	MarkFailed(id uint, attempts int, lastError string, nextAttemptAt time.Time, dead bool) error
+	GetEmails(status string, limit int) ([]model.OutboundEmail, error)
+	GetEmailByID(id uint) (*model.OutboundEmail, error)
+	Requeue(id uint) (bool, error)
+}
+
+type emailRepository struct {
+	db *gorm.DB
+}
+
+func NewEmailRepository(db *gorm.DB) EmailRepository {
+	return &emailRepository{db}
+}
+
+func (r *emailRepository) Enqueue(email *model.OutboundEmail) error {
+	email.Status = model.EmailPending
+	if email.NextAttemptAt.IsZero() {
+		email.NextAttemptAt = time.Now()
+	}
+	return r.db.Create(email).Error
+}
+
+// ClaimDue leases due emails to the caller. A "sending" row whose lease ran out belongs to a
+// worker that died mid-send, so it is due again. Each row is claimed with a conditional update
+// so two instances never send the same email
+func (r *emailRepository) ClaimDue(limit int, lease time.Duration) ([]model.OutboundEmail, error) {
+	now := time.Now()
+
+	var candidates []model.OutboundEmail
+	err := r.db.Where("status IN ? AND next_attempt_at <= ?", []string{model.EmailPending, model.EmailSending}, now).
+		Order("next_attempt_at").
+		Limit(limit).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := make([]model.OutboundEmail, 0, len(candidates))
+	for _, email := range candidates {
+		result := r.db.Model(&model.OutboundEmail{}).
+			Where("id = ? AND status = ? AND next_attempt_at = ?", email.ID, email.Status, email.NextAttemptAt).
+			Updates(map[string]any{
+				"status":          model.EmailSending,
+				"next_attempt_at": now.Add(lease),
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			email.Status = model.EmailSending
+			claimed = append(claimed, email)
+		}
+	}
+	return claimed, nil
+}
+
+func (r *emailRepository) MarkSent(id uint) error {
+	now := time.Now()
+	return r.db.Model(&model.OutboundEmail{}).Where("id = ?", id).Updates(map[string]any{
+		"status":     model.EmailSent,
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": "",
+		"sent_at":    &now,
+	}).Error
+}
+
+func (r *emailRepository) MarkFailed(id uint, attempts int, lastError string, nextAttemptAt time.Time, dead bool) error {
+	status := model.EmailPending
+	if dead {
+		status = model.EmailDead
+	}
+	return r.db.Model(&model.OutboundEmail{}).Where("id = ?", id).Updates(map[string]any{
+		"status":          status,
+		"attempts":        attempts,
+		"last_error":      lastError,
+		"next_attempt_at": nextAttemptAt,
+	}).Error
+}
+
+// GetEmails returns the newest emails first, an empty status means every status
+func (r *emailRepository) GetEmails(status string, limit int) ([]model.OutboundEmail, error) {
+	query := r.db.Model(&model.OutboundEmail{}).Order("id DESC").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	emails := []model.OutboundEmail{}
+	if err := query.Find(&emails).Error; err != nil {
+		return nil, err
+	}
+	return emails, nil
+}
+
+func (r *emailRepository) GetEmailByID(id uint) (*model.OutboundEmail, error) {
+	var email model.OutboundEmail
+	if err := r.db.First(&email, id).Error; err != nil {
+		return nil, err
+	}
+	return &email, nil
+}
+
+// Requeue gives a dead email a fresh set of attempts, false if it isn't dead
+func (r *emailRepository) Requeue(id uint) (bool, error) {
+	result := r.db.Model(&model.OutboundEmail{}).
+		Where("id = ? AND status = ?", id, model.EmailDead).
+		Updates(map[string]any{
+			"status":          model.EmailPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
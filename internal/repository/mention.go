@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"chat/model"
+	"chat/response"
+	"chat/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type MentionRepository interface {
+	GetMentionsByChatIDs(chatIDs []uint) (map[uint][]response.MentionResponse, error)
+	GetUnreadMentions(userID uint, limit int) ([]response.MentionInboxResponse, error)
+	MarkMentionsRead(userID uint, mentionIDs []uint) error
+}
+
+type mentionRepository struct {
+	db *gorm.DB
+}
+
+func NewMentionRepository(db *gorm.DB) MentionRepository {
+	return &mentionRepository{db}
+}
+
+// replaceMentions drops whatever the chat mentioned before, an edit re-resolves them all.
+// tx is the transaction that writes the chat itself
+func replaceMentions(tx *gorm.DB, chatID uint, mentions []model.Mention) error {
+	if err := tx.Where("chat_id = ?", chatID).Delete(&model.Mention{}).Error; err != nil {
+		return err
+	}
+	if len(mentions) == 0 {
+		return nil
+	}
+	for i := range mentions {
+		mentions[i].ChatID = chatID
+	}
+	return tx.Create(&mentions).Error
+}
+
+// GetMentionsByChatIDs rebuilds the spans, a broadcast span is stored once per member
+// so it is folded back into one
+func (r *mentionRepository) GetMentionsByChatIDs(chatIDs []uint) (map[uint][]response.MentionResponse, error) {
+	mentions := make(map[uint][]response.MentionResponse, len(chatIDs))
+	if len(chatIDs) == 0 {
+		return mentions, nil
+	}
+
+	var rows []struct {
+		ChatID     uint
+		Kind       string
+		UserID     uint
+		Username   string
+		SpanStart  int
+		SpanLength int
+	}
+	err := r.db.Model(&model.Mention{}).
+		Select("mentions.chat_id, mentions.kind, mentions.user_id, users.username, mentions.span_start, mentions.span_length").
+		Joins("JOIN users ON users.id = mentions.user_id").
+		Where("mentions.chat_id IN ?", chatIDs).
+		Order("mentions.chat_id, mentions.span_start").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	type span struct {
+		chatID uint
+		start  int
+	}
+	seen := make(map[span]bool)
+	for _, row := range rows {
+		key := span{row.ChatID, row.SpanStart}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		mention := response.MentionResponse{
+			Kind:   row.Kind,
+			Offset: row.SpanStart,
+			Length: row.SpanLength,
+		}
+		if row.Kind == utils.MentionUser {
+			userID := row.UserID
+			mention.UserID = &userID
+			mention.Username = row.Username
+		}
+		mentions[row.ChatID] = append(mentions[row.ChatID], mention)
+	}
+	return mentions, nil
+}
+
+// GetUnreadMentions returns one entry per chat, MAX(kind) prefers "user" over "room"/"here".
+// It skips the user's own chats, deleted chats and rooms the user is no longer in
+func (r *mentionRepository) GetUnreadMentions(userID uint, limit int) ([]response.MentionInboxResponse, error) {
+	inbox := []response.MentionInboxResponse{}
+	err := r.db.Model(&model.Mention{}).
+		Select("MIN(mentions.id) AS id, mentions.chat_id, mentions.room_id, MAX(mentions.kind) AS kind, chats.sender_id, chats.message, chats.created_at AS time").
+		Joins("JOIN chats ON chats.id = mentions.chat_id").
+		Joins("JOIN room_members rm ON rm.room_id = mentions.room_id AND rm.user_id = mentions.user_id").
+		Where("mentions.user_id = ? AND mentions.read_at IS NULL AND chats.deleted_at IS NULL", userID).
+		Where("chats.sender_id IS NULL OR chats.sender_id <> mentions.user_id").
+		Group("mentions.chat_id, mentions.room_id, chats.sender_id, chats.message, chats.created_at").
+		Order("mentions.chat_id DESC").
+		Limit(limit).
+		Scan(&inbox).Error
+	if err != nil {
+		return nil, err
+	}
+	return inbox, nil
+}
+
+// MarkMentionsRead marks every unread mention of the user when mentionIDs is empty.
+// The ids come from the inbox, which shows one id per chat, so the whole chat is marked
+func (r *mentionRepository) MarkMentionsRead(userID uint, mentionIDs []uint) error {
+	query := r.db.Model(&model.Mention{}).Where("user_id = ? AND read_at IS NULL", userID)
+	if len(mentionIDs) > 0 {
+		// plucked first, mysql can't update a table it selects from in a subquery
+		var chatIDs []uint
+		if err := r.db.Model(&model.Mention{}).
+			Where("id IN ? AND user_id = ?", mentionIDs, userID).
+			Distinct().
+			Pluck("chat_id", &chatIDs).Error; err != nil {
+			return err
+		}
+		if len(chatIDs) == 0 {
+			return nil
+		}
+		query = query.Where("chat_id IN ?", chatIDs)
+	}
+	return query.Update("read_at", time.Now()).Error
+}
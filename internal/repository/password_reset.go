@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"chat/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PasswordResetRepository interface {
+	CreateReset(reset *model.PasswordReset) error
+	GetReset(tokenHash string) (*model.PasswordReset, error)
+	ConsumeReset(resetID, userID uint, hashedPassword string) (bool, error)
+}
+
+type passwordResetRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordResetRepository(db *gorm.DB) PasswordResetRepository {
+	return &passwordResetRepository{db}
+}
+
+// CreateReset burns the user's older unused tokens, only the newest email works
+func (r *passwordResetRepository) CreateReset(reset *model.PasswordReset) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.PasswordReset{}).
+			Where("user_id = ? AND used_at IS NULL", reset.UserID).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(reset).Error
+	})
+}
+
+func (r *passwordResetRepository) GetReset(tokenHash string) (*model.PasswordReset, error) {
+	var reset model.PasswordReset
+	if err := r.db.Where("token_hash = ?", tokenHash).First(&reset).Error; err != nil {
+		return nil, err
+	}
+	return &reset, nil
+}
+
+// ConsumeReset marks the token used, sets the new password and revokes every session of the user.
+// false means the token was used in the meantime and nothing was changed
+func (r *passwordResetRepository) ConsumeReset(resetID, userID uint, hashedPassword string) (bool, error) {
+	consumed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&model.PasswordReset{}).
+			Where("id = ? AND used_at IS NULL", resetID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&model.User{}).Where("id = ?", userID).Update("password", hashedPassword).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&model.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+
+		consumed = true
+		return nil
+	})
+	return consumed, err
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"chat/model"
+	"chat/response"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReactionRepository interface {
+	AddReaction(chatID, userID uint, emoji string) (bool, error)
+	RemoveReaction(chatID, userID uint, emoji string) (bool, error)
+	GetReactionsByChatIDs(chatIDs []uint, userID uint) (map[uint][]response.ReactionResponse, error)
+}
+
+type reactionRepository struct {
+	db *gorm.DB
+}
+
+func NewReactionRepository(db *gorm.DB) ReactionRepository {
+	return &reactionRepository{db}
+}
+
+// AddReaction is idempotent, the bool reports whether a new row was written
+func (r *reactionRepository) AddReaction(chatID, userID uint, emoji string) (bool, error) {
+	reaction := &model.Reaction{
+		ChatID: chatID,
+		UserID: userID,
+		Emoji:  emoji,
+	}
+
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(reaction)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *reactionRepository) RemoveReaction(chatID, userID uint, emoji string) (bool, error) {
+	result := r.db.Where("chat_id = ? AND user_id = ? AND emoji = ?", chatID, userID, emoji).Delete(&model.Reaction{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *reactionRepository) GetReactionsByChatIDs(chatIDs []uint, userID uint) (map[uint][]response.ReactionResponse, error) {
+	reactions := make(map[uint][]response.ReactionResponse, len(chatIDs))
+	if len(chatIDs) == 0 {
+		return reactions, nil
+	}
+
+	var rows []struct {
+		ChatID  uint
+		Emoji   string
+		Count   int64
+		Reacted int
+	}
+	err := r.db.Model(&model.Reaction{}).
+		Select("chat_id, emoji, COUNT(*) AS count, MAX(CASE WHEN user_id = ? THEN 1 ELSE 0 END) AS reacted", userID).
+		Where("chat_id IN ?", chatIDs).
+		Group("chat_id, emoji").
+		Order("MIN(id)").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		reactions[row.ChatID] = append(reactions[row.ChatID], response.ReactionResponse{
+			Emoji:   row.Emoji,
+			Count:   row.Count,
+			Reacted: row.Reacted == 1,
+		})
+	}
+	return reactions, nil
+}
@@ -5,6 +5,7 @@ import (
 	"chat/response"
 	"errors"
 	"fmt"
+	"strings"
 
 	"gorm.io/gorm"
 )
@@ -16,16 +17,27 @@ type RoomChatRepository interface {
 	UpdateRoom(roomID uint, name, desc string) error
 	DeleteRoom(roomID uint) error
 	IsRoomExist(roomID uint) (bool, error)
+	IsDirectRoom(roomID uint) (bool, error)
+	GetOrCreateDirectRoom(userID, peerID uint) (*model.RoomChat, bool, error)
 
 	//for room member
 	GetRoomMember(roomID uint) ([]response.RoomMemberResponse, error)
+	GetMemberIDsByUsernames(roomID uint, usernames []string) (map[string]uint, error)
 	AddMembers(roomID uint, targetIDS []uint, adminID uint) error
 	IsUserInRoom(roomID uint, userID uint) (bool, error)
 	IsUserIsAdmin(roomID uint, userID uint) (bool, error)
 	DeleteMembersByAdmin(roomID uint, targetIDS []uint, userID uint) error
 	LeaveRoom(roomID uint, userID uint, targetID uint) error
+	CountMembers(roomID uint) (int64, error)
+
+	//read receipt
+	UpdateLastRead(roomID, userID, chatID uint) (bool, error)
+	GetSeenBy(roomID, chatID, senderID uint) ([]response.SeenByResponse, error)
 }
 
+// how much of the last message the room list shows
+const previewLength = 100
+
 type roomChatRepository struct {
 	db *gorm.DB
 }
@@ -39,18 +51,97 @@ func (r *roomChatRepository) GetRoomChatsByUserID(userID uint) ([]response.GetGr
 	var rooms []response.GetGroupByUserIdResponse
 	err := r.db.
 		Model(&model.RoomChat{}).
-		Select("room_chats.id, room_chats.name, room_chats.desc").
+		Select("room_chats.id, room_chats.name, room_chats.desc, room_chats.type, peer.user_id AS peer_id, pu.username AS peer_username").
 		Joins("JOIN room_members rm ON rm.room_id = room_chats.id").
+		Joins("LEFT JOIN room_members peer ON peer.room_id = room_chats.id AND room_chats.type = ? AND peer.user_id <> rm.user_id", model.RoomTypeDirect).
+		Joins("LEFT JOIN users pu ON pu.id = peer.user_id").
 		Where("rm.user_id = ?", userID).
 		Find(&rooms).Error
+	if err != nil || len(rooms) == 0 {
+		return rooms, err
+	}
 
-	return rooms, err
+	if err := r.fillUnread(rooms, userID); err != nil {
+		return nil, err
+	}
+	if err := r.fillLastMessage(rooms); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+// fillUnread counts chats after the member's read marker, deleted chats and their own don't count
+func (r *roomChatRepository) fillUnread(rooms []response.GetGroupByUserIdResponse, userID uint) error {
+	var counts []struct {
+		RoomID uint
+		Unread int64
+	}
+	err := r.db.Table("room_members rm").
+		Select("rm.room_id, COUNT(c.id) AS unread").
+		Joins("JOIN chats c ON c.room_id = rm.room_id AND c.id > COALESCE(rm.last_read_chat_id, 0) AND c.deleted_at IS NULL AND (c.sender_id IS NULL OR c.sender_id <> rm.user_id)").
+		Where("rm.user_id = ?", userID).
+		Group("rm.room_id").
+		Scan(&counts).Error
+	if err != nil {
+		return err
+	}
+
+	unread := make(map[uint]int64, len(counts))
+	for _, count := range counts {
+		unread[count.RoomID] = count.Unread
+	}
+	for i := range rooms {
+		rooms[i].UnreadCount = unread[rooms[i].ID]
+	}
+	return nil
+}
+
+func (r *roomChatRepository) fillLastMessage(rooms []response.GetGroupByUserIdResponse) error {
+	roomIDs := make([]uint, 0, len(rooms))
+	for _, room := range rooms {
+		roomIDs = append(roomIDs, room.ID)
+	}
+
+	var lastIDs []uint
+	err := r.db.Model(&model.Chat{}).
+		Select("MAX(id)").
+		Where("room_id IN ? AND deleted_at IS NULL", roomIDs).
+		Group("room_id").
+		Scan(&lastIDs).Error
+	if err != nil || len(lastIDs) == 0 {
+		return err
+	}
+
+	var chats []struct {
+		response.ChatPreviewResponse
+		RoomID uint
+	}
+	err = r.db.Model(&model.Chat{}).
+		Select("id, room_id, sender_id, message, created_at AS time").
+		Where("id IN ?", lastIDs).
+		Scan(&chats).Error
+	if err != nil {
+		return err
+	}
+
+	previews := make(map[uint]*response.ChatPreviewResponse, len(chats))
+	for i := range chats {
+		preview := chats[i].ChatPreviewResponse
+		if runes := []rune(preview.Message); len(runes) > previewLength {
+			preview.Message = string(runes[:previewLength]) + "…"
+		}
+		previews[chats[i].RoomID] = &preview
+	}
+	for i := range rooms {
+		rooms[i].LastMessage = previews[rooms[i].ID]
+	}
+	return nil
 }
 
 func (r *roomChatRepository) GetRoomChatByID(roomID uint) (*response.GetGroupByIdResponse, error) {
 	var room model.RoomChat
 	if err := r.db.Model(&room).
-		Select("room_chats.id, room_chats.name, room_chats.desc, room_chats.creator_id").
+		Select("room_chats.id, room_chats.name, room_chats.desc, room_chats.type, room_chats.creator_id").
 		Where("id = ?", roomID).First(&room).Error; err != nil {
 		return nil, err
 	}
@@ -58,6 +149,7 @@ func (r *roomChatRepository) GetRoomChatByID(roomID uint) (*response.GetGroupByI
 		ID:        room.ID,
 		Name:      room.Name,
 		Desc:      room.Desc,
+		Type:      room.Type,
 		CreatorID: room.CreatorID,
 	}
 
@@ -95,18 +187,57 @@ func (r *roomChatRepository) CreateRoomChat(roomChat *model.RoomChat, userID uin
 	return tx.Commit().Error
 }
 
+// GetOrCreateDirectRoom returns the one direct room between the two users, the bool is true
+// when it was created by this call. Both members get the "member" role, a DM has no admin
+func (r *roomChatRepository) GetOrCreateDirectRoom(userID, peerID uint) (*model.RoomChat, bool, error) {
+	key := fmt.Sprintf("%d:%d", min(userID, peerID), max(userID, peerID))
+
+	var room model.RoomChat
+	err := r.db.Where("direct_key = ?", key).First(&room).Error
+	if err == nil {
+		return &room, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	if err := r.db.First(&model.User{}, peerID).Error; err != nil {
+		return nil, false, err
+	}
+
+	room = model.RoomChat{
+		Type:      model.RoomTypeDirect,
+		CreatorID: userID,
+		DirectKey: &key,
+	}
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&room).Error; err != nil {
+			return err
+		}
+
+		members := []model.RoomMember{
+			{RoomID: room.ID, UserID: &userID, Role: "member"},
+			{RoomID: room.ID, UserID: &peerID, Role: "member"},
+		}
+		return tx.Create(&members).Error
+	})
+	if err != nil {
+		// the other user may have opened the same DM at the same time, the unique key kept one
+		var existing model.RoomChat
+		if r.db.Where("direct_key = ?", key).First(&existing).Error == nil {
+			return &existing, false, nil
+		}
+		return nil, false, err
+	}
+	return &room, true, nil
+}
+
 func (r *roomChatRepository) UpdateRoom(roomID uint, name, desc string) error {
-	result := r.db.Model(&model.RoomChat{}).Where("id= ? ", roomID).Updates(map[string]interface{}{
+	// RowsAffected tidak dicek, mysql melaporkan 0 kalau name/desc tidak berubah. Room-nya sudah dicek usecase
+	return r.db.Model(&model.RoomChat{}).Where("id= ? ", roomID).Updates(map[string]interface{}{
 		"name": name,
 		"desc": desc,
-	})
-	if result.Error != nil {
-		return result.Error
-	}
-	if result.RowsAffected == 0 {
-		return errors.New("no chat found to update")
-	}
-	return nil
+	}).Error
 }
 
 func (r *roomChatRepository) DeleteRoom(roomID uint) error {
@@ -146,6 +277,14 @@ func (r *roomChatRepository) IsRoomExist(roomID uint) (bool, error) {
 	return true, nil
 }
 
+func (r *roomChatRepository) IsDirectRoom(roomID uint) (bool, error) {
+	var count int64
+	if err := r.db.Model(&model.RoomChat{}).Where("id = ? AND type = ?", roomID, model.RoomTypeDirect).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
 // room member
 func (r *roomChatRepository) GetRoomMember(roomID uint) ([]response.RoomMemberResponse, error) {
 	var response []response.RoomMemberResponse
@@ -155,6 +294,38 @@ func (r *roomChatRepository) GetRoomMember(roomID uint) ([]response.RoomMemberRe
 	return response, nil
 }
 
+// GetMemberIDsByUsernames keys the result by lowercased username
+func (r *roomChatRepository) GetMemberIDsByUsernames(roomID uint, usernames []string) (map[string]uint, error) {
+	ids := make(map[string]uint)
+	if len(usernames) == 0 {
+		return ids, nil
+	}
+
+	lowered := make([]string, 0, len(usernames))
+	for _, username := range usernames {
+		lowered = append(lowered, strings.ToLower(username))
+	}
+
+	var members []struct {
+		UserID   uint
+		Username string
+	}
+	err := r.db.Model(&model.RoomMember{}).
+		Select("room_members.user_id, users.username").
+		Joins("JOIN users ON users.id = room_members.user_id").
+		// LOWER keeps the match case-insensitive outside mysql too
+		Where("room_members.room_id = ? AND LOWER(users.username) IN ?", roomID, lowered).
+		Scan(&members).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, member := range members {
+		ids[strings.ToLower(member.Username)] = member.UserID
+	}
+	return ids, nil
+}
+
 func (r *roomChatRepository) AddMembers(roomID uint, targetIDs []uint, adminID uint) error {
 	var isExist int64
 	r.db.Model(&model.RoomMember{}).
@@ -198,6 +369,38 @@ func (r *roomChatRepository) DeleteMembersByAdmin(roomID uint, targetIDS []uint,
 
 }
 
+func (r *roomChatRepository) CountMembers(roomID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.RoomMember{}).Where("room_id = ?", roomID).Count(&count).Error
+	return count, err
+}
+
+// UpdateLastRead only moves the marker forward, the bool reports whether it moved
+func (r *roomChatRepository) UpdateLastRead(roomID, userID, chatID uint) (bool, error) {
+	result := r.db.Model(&model.RoomMember{}).
+		Where("room_id = ? AND user_id = ?", roomID, userID).
+		Where("last_read_chat_id IS NULL OR last_read_chat_id < ?", chatID).
+		Update("last_read_chat_id", chatID)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *roomChatRepository) GetSeenBy(roomID, chatID, senderID uint) ([]response.SeenByResponse, error) {
+	seenBy := []response.SeenByResponse{}
+	err := r.db.Model(&model.RoomMember{}).
+		Select("room_members.user_id, users.username").
+		Joins("JOIN users ON users.id = room_members.user_id").
+		Where("room_members.room_id = ? AND room_members.last_read_chat_id >= ? AND room_members.user_id <> ?", roomID, chatID, senderID).
+		Order("users.username").
+		Scan(&seenBy).Error
+	if err != nil {
+		return nil, err
+	}
+	return seenBy, nil
+}
+
 func (r *roomChatRepository) LeaveRoom(roomID uint, userID uint, targetID uint) error {
 	return r.db.Where("room_id = ? AND user_id = ?", roomID, targetID).Delete(&model.RoomMember{}).Error
 }
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"chat/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SessionRepository interface {
+	CreateSession(session *model.Session, token *model.RefreshToken) error
+	GetRefreshToken(tokenHash string) (*model.RefreshToken, error)
+	RotateRefreshToken(oldID uint, next *model.RefreshToken) (bool, error)
+	RevokeSession(sessionID string) error
+	RevokeUserSessions(userID uint) error
+	RevokeOtherSessions(userID uint, keepSessionID string) error
+	IsSessionActive(sessionID string) (bool, error)
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{db}
+}
+
+func (r *sessionRepository) CreateSession(session *model.Session, token *model.RefreshToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+		token.SessionID = session.ID
+		return tx.Create(token).Error
+	})
+}
+
+// GetRefreshToken preloads the session and its user, refresh needs both
+func (r *sessionRepository) GetRefreshToken(tokenHash string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	if err := r.db.Preload("Session.User").Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RotateRefreshToken marks the old token used and stores its successor.
+// false means the old token was already used, two refreshes raced with the same token
+func (r *sessionRepository) RotateRefreshToken(oldID uint, next *model.RefreshToken) (bool, error) {
+	rotated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", oldID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		rotated = true
+		return tx.Create(next).Error
+	})
+	return rotated, err
+}
+
+func (r *sessionRepository) RevokeSession(sessionID string) error {
+	return r.db.Model(&model.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *sessionRepository) RevokeUserSessions(userID uint) error {
+	return r.db.Model(&model.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *sessionRepository) RevokeOtherSessions(userID uint, keepSessionID string) error {
+	return r.db.Model(&model.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepSessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *sessionRepository) IsSessionActive(sessionID string) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
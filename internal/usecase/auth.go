@@ -1,12 +1,21 @@
 package usecase
 
 import (
+	"chat/internal/mail"
 	"chat/internal/repository"
 	"chat/model"
+	"chat/response"
+	"context"
 	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
 
 	"chat/utils"
 	"errors"
+
+	"gorm.io/gorm"
 )
 
 type UserUsecase interface {
@@ -15,15 +24,52 @@ type UserUsecase interface {
 	UpdateUser(firstEmail, username, email string) error
 	DeleteUser(email string) error
 	ValidateUser(email string) error
+	VerifyAccount(token string) error
 	ResendLinkVerif(email string) error
+	StartSession(user *model.User) (*response.TokenResponse, error)
+	StartMFA(user *model.User) (string, error)
+	Refresh(refreshToken string) (*response.TokenResponse, error)
+	Logout(sessionID string) error
+	LogoutAll(userID uint) error
+	ForgotPassword(email string) error
+	ResetPassword(token, password string) error
+	ChangePassword(email, sessionID, currentPassword, newPassword string) error
+	EnrollTOTP(email string) (*response.TOTPEnrollResponse, error)
+	ConfirmTOTP(email, code string) ([]string, error)
+	DisableTOTP(email, password, code string) error
+	VerifyMFA(mfaToken, code string) (*response.TokenResponse, error)
+	Wait(ctx context.Context) error
 }
 
+const (
+	recoveryCodeCount = 10
+
+	// kode salah di /login/2fa sebelum dikunci, token MFA yang ada ikut hangus dan harus login ulang
+	maxMFAFailures = 5
+	mfaLockout     = 15 * time.Minute
+)
+
 type userUsecase struct {
-	userRepo repository.UserRepository
+	userRepo    repository.UserRepository
+	sessionRepo repository.SessionRepository
+	resetRepo   repository.PasswordResetRepository
+	mailer      mail.Mailer
+	templates   *mail.Templates
+	jwt         *utils.JWTManager
+
+	// email yang dikirim di background, ditunggu saat shutdown sebelum database ditutup
+	background sync.WaitGroup
 }
 
-func NewUserUsecase(userRepo repository.UserRepository) UserUsecase {
-	return &userUsecase{userRepo}
+func NewUserUsecase(userRepo repository.UserRepository, sessionRepo repository.SessionRepository, resetRepo repository.PasswordResetRepository, mailer mail.Mailer, templates *mail.Templates, jwt *utils.JWTManager) UserUsecase {
+	return &userUsecase{
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		resetRepo:   resetRepo,
+		mailer:      mailer,
+		templates:   templates,
+		jwt:         jwt,
+	}
 }
 
 func (u *userUsecase) Register(username, email, password string) error {
@@ -42,12 +88,15 @@ func (u *userUsecase) Register(username, email, password string) error {
 		return utils.ErrInternal
 	}
 
-	tokenJWT, err := utils.GenerateJWTVerification(email)
+	tokenJWT, err := u.jwt.GenerateJWTVerification(email)
 	if err != nil {
 		return err
 	}
 
-	utils.SendEmail(email, tokenJWT)
+	// akun sudah dibuat, kalau email gagal user masih bisa minta resend link
+	if err := u.sendVerification(email, tokenJWT); err != nil {
+		log.Printf("❌ Failed to send verification email to %s: %v", email, err)
+	}
 
 	return nil
 }
@@ -91,6 +140,15 @@ func (u *userUsecase) ValidateUser(email string) error {
 	return u.userRepo.ValidateUser(email)
 }
 
+// VerifyAccount checks the token from the verification link, purpose tokens are not accepted
+func (u *userUsecase) VerifyAccount(token string) error {
+	claims, err := u.jwt.ParseJWT(token)
+	if err != nil || claims.Purpose != "" {
+		return utils.ErrInvalidToken
+	}
+	return u.userRepo.ValidateUser(claims.Email)
+}
+
 func (u *userUsecase) ResendLinkVerif(email string) error {
 	if !utils.IsValidEmail(email) {
 		return utils.ErrBadRequest
@@ -104,12 +162,473 @@ func (u *userUsecase) ResendLinkVerif(email string) error {
 		return err
 	}
 
-	tokenJWT, err := utils.GenerateJWTVerification(email)
+	tokenJWT, err := u.jwt.GenerateJWTVerification(email)
+	if err != nil {
+		return err
+	}
+
+	return u.sendVerification(email, tokenJWT)
+}
+
+// StartSession opens a new refresh token family for a successful login
+func (u *userUsecase) StartSession(user *model.User) (*response.TokenResponse, error) {
+	sessionID, err := utils.GenerateSessionID()
+	if err != nil {
+		return nil, utils.ErrInternal
+	}
+
+	raw, token, err := newRefreshToken(sessionID)
+	if err != nil {
+		return nil, utils.ErrInternal
+	}
+
+	session := &model.Session{ID: sessionID, UserID: user.ID}
+	if err := u.sessionRepo.CreateSession(session, token); err != nil {
+		return nil, utils.ErrInternal
+	}
+
+	return u.issueTokens(user, sessionID, raw)
+}
+
+// StartMFA returns the short-lived token the second login step expects
+func (u *userUsecase) StartMFA(user *model.User) (string, error) {
+	token, err := u.jwt.GenerateJWTMFAPending(user.Email)
+	if err != nil {
+		return "", utils.ErrInternal
+	}
+	return token, nil
+}
+
+// Refresh rotates the refresh token. Presenting a token that was already rotated means
+// it leaked, so the whole family is revoked and both holders have to log in again
+func (u *userUsecase) Refresh(refreshToken string) (*response.TokenResponse, error) {
+	token, err := u.sessionRepo.GetRefreshToken(utils.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrInvalidToken
+		}
+		return nil, utils.ErrInternal
+	}
+
+	if token.Session.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, utils.ErrInvalidToken
+	}
+	if token.UsedAt != nil {
+		return nil, u.revokeReused(token.SessionID)
+	}
+
+	raw, next, err := newRefreshToken(token.SessionID)
+	if err != nil {
+		return nil, utils.ErrInternal
+	}
+
+	rotated, err := u.sessionRepo.RotateRefreshToken(token.ID, next)
+	if err != nil {
+		return nil, utils.ErrInternal
+	}
+	if !rotated {
+		return nil, u.revokeReused(token.SessionID)
+	}
+
+	return u.issueTokens(&token.Session.User, token.SessionID, raw)
+}
+
+func (u *userUsecase) Logout(sessionID string) error {
+	if sessionID == "" {
+		return utils.ErrInvalidToken
+	}
+	if err := u.sessionRepo.RevokeSession(sessionID); err != nil {
+		return utils.ErrInternal
+	}
+	return nil
+}
+
+func (u *userUsecase) LogoutAll(userID uint) error {
+	if err := u.sessionRepo.RevokeUserSessions(userID); err != nil {
+		return utils.ErrInternal
+	}
+	return nil
+}
+
+// ForgotPassword returns nil for unknown emails too, the caller must not learn who is registered.
+// Lookup, token and email all happen in the background so both cases answer equally fast
+func (u *userUsecase) ForgotPassword(email string) error {
+	if !utils.IsValidEmail(email) {
+		return utils.ErrBadRequest
+	}
+
+	u.goBackground(func() { u.sendPasswordReset(email) })
+	return nil
+}
+
+func (u *userUsecase) sendPasswordReset(email string) {
+	user, err := u.userRepo.Login(email)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("❌ Failed to look up %s for password reset: %v", email, err)
+		}
+		return
+	}
+
+	token, err := u.jwt.GenerateJWTPasswordReset(user.Email)
+	if err != nil {
+		log.Printf("❌ Failed to create password reset token: %v", err)
+		return
+	}
+
+	reset := &model.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(utils.PasswordResetTTL),
+	}
+	if err := u.resetRepo.CreateReset(reset); err != nil {
+		log.Printf("❌ Failed to save password reset: %v", err)
+		return
+	}
+
+	msg, err := u.templates.PasswordReset(user.Email, token, utils.PasswordResetTTL)
+	if err != nil {
+		log.Printf("❌ Failed to render password reset: %v", err)
+		return
+	}
+	u.send(msg)
+}
+
+func (u *userUsecase) ResetPassword(token, password string) error {
+	claims, err := u.jwt.ParseJWT(token)
+	if err != nil || claims.Purpose != utils.PurposePasswordReset {
+		return utils.ErrInvalidToken
+	}
+
+	if !utils.IsValidPassword(password) {
+		return utils.ErrWeakPassword
+	}
+
+	reset, err := u.resetRepo.GetReset(utils.HashToken(token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.ErrInvalidToken
+		}
+		return utils.ErrInternal
+	}
+	if reset.UsedAt != nil || time.Now().After(reset.ExpiresAt) {
+		return utils.ErrInvalidToken
+	}
+
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return utils.ErrInternal
+	}
+
+	consumed, err := u.resetRepo.ConsumeReset(reset.ID, reset.UserID, hashedPassword)
+	if err != nil {
+		return utils.ErrInternal
+	}
+	if !consumed {
+		return utils.ErrInvalidToken
+	}
+
+	u.notifyPasswordChanged(claims.Email)
+	return nil
+}
+
+// ChangePassword keeps the caller's session and logs every other device out
+func (u *userUsecase) ChangePassword(email, sessionID, currentPassword, newPassword string) error {
+	user, err := u.getUser(email)
+	if err != nil {
+		return err
+	}
+
+	if !utils.CheckPassword(user.Password, currentPassword) {
+		return utils.ErrWrongPassword
+	}
+	if !utils.IsValidPassword(newPassword) {
+		return utils.ErrWeakPassword
+	}
+	if currentPassword == newPassword {
+		return utils.ErrSamePassword
+	}
+
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return utils.ErrInternal
+	}
+
+	if err := u.userRepo.UpdatePassword(user.ID, hashedPassword); err != nil {
+		return utils.ErrInternal
+	}
+	if err := u.sessionRepo.RevokeOtherSessions(user.ID, sessionID); err != nil {
+		return utils.ErrInternal
+	}
+
+	u.notifyPasswordChanged(user.Email)
+	return nil
+}
+
+// EnrollTOTP (re)starts enrollment, the secret is unused until ConfirmTOTP sees a valid code
+func (u *userUsecase) EnrollTOTP(email string) (*response.TOTPEnrollResponse, error) {
+	user, err := u.getUser(email)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, utils.ErrTOTPEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, utils.ErrInternal
+	}
+	if err := u.userRepo.SetTOTPSecret(user.ID, secret); err != nil {
+		return nil, utils.ErrInternal
+	}
+
+	return &response.TOTPEnrollResponse{
+		Secret:     secret,
+		OtpauthURI: utils.TOTPURI(secret, user.Email),
+	}, nil
+}
+
+// ConfirmTOTP enables 2fa and returns the recovery codes, the only time they are shown in plain text
+func (u *userUsecase) ConfirmTOTP(email, code string) ([]string, error) {
+	user, err := u.getUser(email)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, utils.ErrTOTPEnabled
+	}
+	if user.TOTPSecret == nil {
+		return nil, utils.ErrTOTPDisabled
+	}
+
+	step, ok := utils.ValidateTOTP(*user.TOTPSecret, code, time.Now())
+	if !ok {
+		return nil, utils.ErrInvalidCode
+	}
+	if _, err := u.userRepo.UseTOTPStep(user.ID, step); err != nil {
+		return nil, utils.ErrInternal
+	}
+
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, utils.ErrInternal
+	}
+	hashes := make([]string, 0, len(codes))
+	for _, c := range codes {
+		hashes = append(hashes, utils.HashToken(c))
+	}
+
+	if err := u.userRepo.EnableTOTP(user.ID, strings.Join(hashes, ",")); err != nil {
+		return nil, utils.ErrInternal
+	}
+	return codes, nil
+}
+
+// DisableTOTP wants both the password and a code, a stolen access token alone is not enough
+func (u *userUsecase) DisableTOTP(email, password, code string) error {
+	user, err := u.getUser(email)
 	if err != nil {
 		return err
 	}
+	if !user.TOTPEnabled {
+		return utils.ErrTOTPDisabled
+	}
+	if locked := user.MFALockedAt; locked != nil && time.Since(*locked) < mfaLockout {
+		return utils.ErrTooManyTries
+	}
+	if !utils.CheckPassword(user.Password, password) {
+		return utils.ErrWrongPassword
+	}
+	if err := u.checkSecondFactorLimited(user, code); err != nil {
+		return err
+	}
+
+	if err := u.userRepo.DisableTOTP(user.ID); err != nil {
+		return utils.ErrInternal
+	}
+	return nil
+}
 
-	utils.SendEmail(email, tokenJWT)
+// VerifyMFA is the second login step, it accepts a totp code or an unused recovery code
+func (u *userUsecase) VerifyMFA(mfaToken, code string) (*response.TokenResponse, error) {
+	claims, err := u.jwt.ParseJWT(mfaToken)
+	if err != nil || claims.Purpose != utils.PurposeMFAPending {
+		return nil, utils.ErrInvalidToken
+	}
+
+	user, err := u.getUser(claims.Email)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, utils.ErrInvalidToken
+	}
+	if locked := user.MFALockedAt; locked != nil {
+		// iat dibulatkan ke detik, token yang terbit di detik yang sama dengan lock ikut hangus
+		if claims.IssuedAt == nil || !claims.IssuedAt.After(*locked) {
+			return nil, utils.ErrInvalidToken
+		}
+	}
 
+	if err := u.checkSecondFactorLimited(user, code); err != nil {
+		return nil, err
+	}
+	return u.StartSession(user)
+}
+
+// checkSecondFactorLimited is checkSecondFactor behind the lockout, every wrong code counts
+// toward maxMFAFailures whether it came from login or from disabling 2FA
+func (u *userUsecase) checkSecondFactorLimited(user *model.User, code string) error {
+	if locked := user.MFALockedAt; locked != nil && time.Since(*locked) < mfaLockout {
+		return utils.ErrTooManyTries
+	}
+
+	if err := u.checkSecondFactor(user, code); err != nil {
+		if err != utils.ErrInvalidCode {
+			return err
+		}
+		locked, lockErr := u.userRepo.RecordMFAFailure(user.ID, maxMFAFailures)
+		if lockErr != nil {
+			return utils.ErrInternal
+		}
+		if locked {
+			return utils.ErrTooManyTries
+		}
+		return err
+	}
+	if user.MFAFailures > 0 {
+		if err := u.userRepo.ResetMFAFailures(user.ID); err != nil {
+			return utils.ErrInternal
+		}
+	}
 	return nil
 }
+
+func (u *userUsecase) checkSecondFactor(user *model.User, code string) error {
+	code = strings.TrimSpace(code)
+
+	if step, ok := utils.ValidateTOTP(*user.TOTPSecret, code, time.Now()); ok {
+		fresh, err := u.userRepo.UseTOTPStep(user.ID, step)
+		if err != nil {
+			return utils.ErrInternal
+		}
+		if !fresh {
+			return utils.ErrInvalidCode
+		}
+		return nil
+	}
+
+	hash := utils.HashToken(strings.ToLower(code))
+	hashes := strings.Split(user.RecoveryCodes, ",")
+	for i, h := range hashes {
+		if h == "" || h != hash {
+			continue
+		}
+
+		remaining := append(hashes[:i:i], hashes[i+1:]...)
+		swapped, err := u.userRepo.ReplaceRecoveryCodes(user.ID, user.RecoveryCodes, strings.Join(remaining, ","))
+		if err != nil {
+			return utils.ErrInternal
+		}
+		if !swapped {
+			return utils.ErrInvalidCode
+		}
+		return nil
+	}
+	return utils.ErrInvalidCode
+}
+
+func (u *userUsecase) getUser(email string) (*model.User, error) {
+	user, err := u.userRepo.Login(email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrUserNotFound
+		}
+		return nil, utils.ErrInternal
+	}
+	return user, nil
+}
+
+func (u *userUsecase) sendVerification(email, token string) error {
+	msg, err := u.templates.Verification(email, token, utils.VerificationTTL)
+	if err != nil {
+		return err
+	}
+	return u.mailer.Send(msg)
+}
+
+// notifyPasswordChanged is best effort, the change already happened
+func (u *userUsecase) notifyPasswordChanged(email string) {
+	msg, err := u.templates.Notification(email, "Password kamu diubah",
+		"Password akun kamu baru saja diubah dan semua device lain sudah logout. Kalau ini bukan kamu, segera reset password.", "")
+	if err != nil {
+		log.Printf("❌ Failed to render notification: %v", err)
+		return
+	}
+	u.goBackground(func() { u.send(msg) })
+}
+
+func (u *userUsecase) goBackground(fn func()) {
+	u.background.Add(1)
+	go func() {
+		defer u.background.Done()
+		fn()
+	}()
+}
+
+// Wait blocks until every background email has been handed to the mailer or ctx is done.
+// Call it after the HTTP server stopped, nothing starts new ones then
+func (u *userUsecase) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		u.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (u *userUsecase) send(msg mail.Message) {
+	if err := u.mailer.Send(msg); err != nil {
+		log.Printf("❌ Failed to send %q to %s: %v", msg.Subject, msg.To, err)
+	}
+}
+
+func (u *userUsecase) revokeReused(sessionID string) error {
+	if err := u.sessionRepo.RevokeSession(sessionID); err != nil {
+		return utils.ErrInternal
+	}
+	return utils.ErrTokenReused
+}
+
+func newRefreshToken(sessionID string) (string, *model.RefreshToken, error) {
+	raw, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return raw, &model.RefreshToken{
+		SessionID: sessionID,
+		TokenHash: utils.HashToken(raw),
+		ExpiresAt: time.Now().Add(utils.RefreshTokenTTL),
+	}, nil
+}
+
+func (u *userUsecase) issueTokens(user *model.User, sessionID, refreshToken string) (*response.TokenResponse, error) {
+	accessToken, err := u.jwt.GenerateJWTLogin(user.ID, user.Email, sessionID)
+	if err != nil {
+		return nil, utils.ErrInternal
+	}
+
+	return &response.TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(utils.AccessTokenTTL.Seconds()),
+	}, nil
+}
@@ -1,31 +1,72 @@
 package usecase
 
 import (
+	"chat/internal/realtime"
 	"chat/internal/repository"
+	"chat/model"
 	"chat/response"
 	"chat/utils"
+	"chat/utils/metrics"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
 )
 
 type ChatUsecase interface {
-	CreateChat(message string, roomID, userID uint) (*response.ChatResponse, error)
-	GetAllChatByRoomId(roomid uint) ([]response.ChatResponse, error)
-	UpdateChat(roomID, userID uint, message string) error
-	DeleteChat(roomID, userID uint) error
+	CreateChat(message string, roomID, userID uint, parentID *uint) (*response.ChatResponse, error)
+	GetAllChatByRoomId(roomid, userID uint, cursor repository.ChatCursor) (*response.ChatPageResponse, error)
+	GetReplies(roomID, chatID, userID uint, cursor repository.ChatCursor) (*response.ChatPageResponse, error)
+	UpdateChat(roomID, chatID, userID uint, message string) (*response.ChatResponse, error)
+	DeleteChat(roomID, chatID, userID uint) error
+	GetChatRevisions(roomID, chatID, userID uint) ([]response.ChatRevisionResponse, error)
+	PurgeChat(roomID, chatID, adminID uint) error
+
+	//reaction
+	AddReaction(roomID, chatID, userID uint, emoji string) error
+	RemoveReaction(roomID, chatID, userID uint, emoji string) error
+
+	//read receipt
+	MarkRead(roomID, chatID, userID uint) error
+	GetSeenBy(roomID, chatID, userID uint) ([]response.SeenByResponse, error)
+
+	//mention
+	GetUnreadMentions(userID uint) ([]response.MentionInboxResponse, error)
+	MarkMentionsRead(userID uint, mentionIDs []uint) error
 }
 
+const (
+	defaultChatPageSize = 50
+	maxChatPageSize     = 100
+
+	// matches the reactions.emoji column size
+	maxEmojiLength = 64
+
+	maxMentionInbox = 100
+
+	// "seen by" is only worked out for rooms up to this size
+	maxSeenByMembers = 50
+)
+
 type chatUsecase struct {
 	chatRepo     repository.ChatRepository
 	roomChatRepo repository.RoomChatRepository
+	reactionRepo repository.ReactionRepository
+	mentionRepo  repository.MentionRepository
+	broadcaster  realtime.Broadcaster
 }
 
-func NewChatUsecase(chatRepo repository.ChatRepository, roomChatRepo repository.RoomChatRepository) ChatUsecase {
+func NewChatUsecase(chatRepo repository.ChatRepository, roomChatRepo repository.RoomChatRepository, reactionRepo repository.ReactionRepository, mentionRepo repository.MentionRepository, broadcaster realtime.Broadcaster) ChatUsecase {
 	return &chatUsecase{
 		chatRepo:     chatRepo,
 		roomChatRepo: roomChatRepo,
+		reactionRepo: reactionRepo,
+		mentionRepo:  mentionRepo,
+		broadcaster:  broadcaster,
 	}
 }
 
-func (u *chatUsecase) CreateChat(message string, roomID, userID uint) (*response.ChatResponse, error) {
+func (u *chatUsecase) CreateChat(message string, roomID, userID uint, parentID *uint) (*response.ChatResponse, error) {
 	exist, err := u.roomChatRepo.IsUserInRoom(roomID, userID)
 	if !exist {
 		return nil, utils.ErrUnauthorized
@@ -42,26 +83,299 @@ func (u *chatUsecase) CreateChat(message string, roomID, userID uint) (*response
 		return nil, err
 	}
 
-	response, err := u.chatRepo.CreateChat(message, roomID, userID)
+	// threads are one level deep, a reply to a reply goes to the same root
+	if parentID != nil {
+		parent, err := u.getChat(roomID, *parentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent.DeletedAt != nil {
+			return nil, utils.ErrChatNotFound
+		}
+		if parent.ParentID != nil {
+			parentID = parent.ParentID
+		}
+	}
+
+	mentions, err := u.resolveMentions(roomID, userID, message)
+	if err != nil {
+		return nil, err
+	}
+
+	chat, err := u.chatRepo.CreateChat(message, roomID, userID, parentID, mentions)
 	if err != nil {
 		return nil, err
 	}
+
+	metrics.MessageCreated()
+
+	response, err := u.chatResponse(chat.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	u.broadcaster.Publish(realtime.Event{
+		Type:   realtime.EventChatCreated,
+		RoomID: roomID,
+		Data:   forRoom(*response),
+	})
 	return response, nil
 }
 
-func (u *chatUsecase) GetAllChatByRoomId(roomid uint) ([]response.ChatResponse, error) {
+// chatResponse reads the stored chat back and decorates it, so create and update answer
+// with exactly what the listings will show
+func (u *chatUsecase) chatResponse(chatID, userID uint) (*response.ChatResponse, error) {
+	chat, err := u.chatRepo.GetChatResponse(chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	chats := []response.ChatResponse{*chat}
+	if err := u.decorate(chats, userID); err != nil {
+		return nil, err
+	}
+	return &chats[0], nil
+}
+
+// forRoom clears the caller's own reacted flags, an event goes to everyone in the room
+func forRoom(chat response.ChatResponse) response.ChatResponse {
+	reactions := make([]response.ReactionResponse, 0, len(chat.Reactions))
+	for _, reaction := range chat.Reactions {
+		reaction.Reacted = false
+		reactions = append(reactions, reaction)
+	}
+	chat.Reactions = reactions
+	return chat
+}
+
+func (u *chatUsecase) GetAllChatByRoomId(roomid, userID uint, cursor repository.ChatCursor) (*response.ChatPageResponse, error) {
+	cursor, err := normalizeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
 	exist, err := u.roomChatRepo.IsRoomExist(roomid)
 	if !exist {
+		return nil, utils.ErrRoomNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := u.roomChatRepo.IsUserInRoom(roomid, userID)
+	if !member {
 		return nil, utils.ErrUnauthorized
 	}
 	if err != nil {
 		return nil, err
 	}
 
-	return u.chatRepo.GetAllChatByRoomID(roomid)
+	page, err := u.chatRepo.GetAllChatByRoomID(roomid, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.decorate(page.Chats, userID); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (u *chatUsecase) GetReplies(roomID, chatID, userID uint, cursor repository.ChatCursor) (*response.ChatPageResponse, error) {
+	cursor, err := normalizeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := u.roomChatRepo.IsUserInRoom(roomID, userID)
+	if !member {
+		return nil, utils.ErrUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// a deleted root keeps its thread readable, the tombstone is enough
+	if _, err := u.getChat(roomID, chatID); err != nil {
+		return nil, err
+	}
+
+	page, err := u.chatRepo.GetReplies(roomID, chatID, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.decorate(page.Chats, userID); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// decorate attaches reaction counts and mention spans, tombstones keep empty lists
+func (u *chatUsecase) decorate(chats []response.ChatResponse, userID uint) error {
+	chatIDs := make([]uint, 0, len(chats))
+	for _, chat := range chats {
+		if chat.DeletedAt == nil {
+			chatIDs = append(chatIDs, chat.ID)
+		}
+	}
+
+	reactions, err := u.reactionRepo.GetReactionsByChatIDs(chatIDs, userID)
+	if err != nil {
+		return err
+	}
+
+	mentions, err := u.mentionRepo.GetMentionsByChatIDs(chatIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range chats {
+		chats[i].Reactions = reactions[chats[i].ID]
+		if chats[i].Reactions == nil {
+			chats[i].Reactions = []response.ReactionResponse{}
+		}
+		chats[i].Mentions = mentions[chats[i].ID]
+		if chats[i].Mentions == nil {
+			chats[i].Mentions = []response.MentionResponse{}
+		}
+	}
+	return nil
+}
+
+// resolveMentions resolves @name against the room members, one row per recipient. The chat
+// repository stores them with the chat. @room and @here only count when the sender is a room
+// admin, otherwise they stay plain text
+func (u *chatUsecase) resolveMentions(roomID, senderID uint, message string) ([]model.Mention, error) {
+	tokens := utils.ParseMentions(message)
+
+	var usernames []string
+	broadcast := false
+	for _, token := range tokens {
+		switch name := strings.ToLower(token.Name); name {
+		case utils.MentionRoom, utils.MentionHere:
+			broadcast = true
+		default:
+			usernames = append(usernames, token.Name)
+		}
+	}
+
+	var members []response.RoomMemberResponse
+	if broadcast {
+		isAdmin, err := u.roomChatRepo.IsUserIsAdmin(roomID, senderID)
+		if err != nil {
+			return nil, err
+		}
+		if isAdmin {
+			members, err = u.roomChatRepo.GetRoomMember(roomID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	userIDs, err := u.roomChatRepo.GetMemberIDsByUsernames(roomID, usernames)
+	if err != nil {
+		return nil, err
+	}
+
+	// the sender's own rows are kept so the spans survive a reload, the inbox skips them
+	mentions := []model.Mention{}
+	for _, token := range tokens {
+		name := strings.ToLower(token.Name)
+		mention := model.Mention{
+			RoomID:     roomID,
+			SpanStart:  token.Offset,
+			SpanLength: token.Length,
+		}
+
+		switch name {
+		case utils.MentionRoom, utils.MentionHere:
+			mention.Kind = name
+			for _, member := range members {
+				mention.UserID = member.UserID
+				mentions = append(mentions, mention)
+			}
+		default:
+			userID, ok := userIDs[name]
+			if !ok {
+				continue
+			}
+			mention.Kind = utils.MentionUser
+			mention.UserID = userID
+			mentions = append(mentions, mention)
+		}
+	}
+	return mentions, nil
+}
+
+func (u *chatUsecase) GetUnreadMentions(userID uint) ([]response.MentionInboxResponse, error) {
+	return u.mentionRepo.GetUnreadMentions(userID, maxMentionInbox)
+}
+
+func (u *chatUsecase) MarkMentionsRead(userID uint, mentionIDs []uint) error {
+	return u.mentionRepo.MarkMentionsRead(userID, mentionIDs)
+}
+
+func normalizeCursor(cursor repository.ChatCursor) (repository.ChatCursor, error) {
+	if cursor.Before != 0 && cursor.After != 0 {
+		return cursor, utils.ErrBadRequest
+	}
+	if cursor.Limit <= 0 {
+		cursor.Limit = defaultChatPageSize
+	}
+	cursor.Limit = min(cursor.Limit, maxChatPageSize)
+	return cursor, nil
 }
 
-func (u *chatUsecase) UpdateChat(roomID, userID uint, message string) error {
+func (u *chatUsecase) UpdateChat(roomID, chatID, userID uint, message string) (*response.ChatResponse, error) {
+	exist, err := u.roomChatRepo.IsUserInRoom(roomID, userID)
+	if !exist {
+		return nil, utils.ErrUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	chat, err := u.getChat(roomID, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	if chat.DeletedAt != nil {
+		return nil, utils.ErrChatNotFound
+	}
+
+	// only the sender can edit a message
+	if chat.SenderID == nil || *chat.SenderID != userID {
+		return nil, utils.ErrUnauthorized
+	}
+
+	// same text is not an edit, don't add an empty revision
+	if chat.Message != message {
+		mentions, err := u.resolveMentions(roomID, userID, message)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := u.chatRepo.UpdateChat(chatID, message, mentions); err != nil {
+			return nil, err
+		}
+	}
+
+	response, err := u.chatResponse(chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	u.broadcaster.Publish(realtime.Event{
+		Type:   realtime.EventChatUpdated,
+		RoomID: roomID,
+		Data:   forRoom(*response),
+	})
+	return response, nil
+}
+
+func (u *chatUsecase) DeleteChat(roomID, chatID, userID uint) error {
 	exist, err := u.roomChatRepo.IsUserInRoom(roomID, userID)
 	if !exist {
 		return utils.ErrUnauthorized
@@ -70,18 +384,147 @@ func (u *chatUsecase) UpdateChat(roomID, userID uint, message string) error {
 		return err
 	}
 
-	existroom, err := u.roomChatRepo.IsRoomExist(roomID)
-	if !existroom {
-		return utils.ErrRoomNotFound
+	chat, err := u.getChat(roomID, chatID)
+	if err != nil {
+		return err
+	}
+
+	if chat.DeletedAt != nil {
+		return utils.ErrChatNotFound
+	}
+
+	// the sender can delete their own message, a room admin can delete any
+	if chat.SenderID == nil || *chat.SenderID != userID {
+		isAdmin, err := u.roomChatRepo.IsUserIsAdmin(roomID, userID)
+		if err != nil {
+			return err
+		}
+		if !isAdmin {
+			return utils.ErrUnauthorized
+		}
+	}
+
+	if err := u.chatRepo.DeleteChat(chatID, userID); err != nil {
+		return err
+	}
+
+	u.broadcaster.Publish(realtime.Event{
+		Type:   realtime.EventChatDeleted,
+		RoomID: roomID,
+		Data: map[string]any{
+			"id":         chatID,
+			"room_id":    roomID,
+			"deleted_by": userID,
+		},
+	})
+	return nil
+}
+
+func (u *chatUsecase) GetChatRevisions(roomID, chatID, userID uint) ([]response.ChatRevisionResponse, error) {
+	exist, err := u.roomChatRepo.IsUserInRoom(roomID, userID)
+	if !exist {
+		return nil, utils.ErrUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	chat, err := u.getChat(roomID, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	// what a deleted message said is only for the room admins
+	if chat.DeletedAt != nil {
+		isAdmin, err := u.roomChatRepo.IsUserIsAdmin(roomID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !isAdmin {
+			return nil, utils.ErrChatNotFound
+		}
+	}
+
+	return u.chatRepo.GetChatRevisions(chatID)
+}
+
+func (u *chatUsecase) PurgeChat(roomID, chatID, adminID uint) error {
+	exist, err := u.roomChatRepo.IsUserInRoom(roomID, adminID)
+	if !exist {
+		return utils.ErrUnauthorized
+	}
+	if err != nil {
+		return err
+	}
+
+	isAdmin, err := u.roomChatRepo.IsUserIsAdmin(roomID, adminID)
+	if !isAdmin {
+		return utils.ErrUnauthorized
 	}
 	if err != nil {
 		return err
 	}
 
-	return u.chatRepo.UpdateChat(roomID, userID, message)
+	if _, err := u.getChat(roomID, chatID); err != nil {
+		return err
+	}
+
+	replyIDs, err := u.chatRepo.PurgeChat(chatID)
+	if err != nil {
+		return err
+	}
+
+	u.broadcaster.Publish(realtime.Event{
+		Type:   realtime.EventChatPurged,
+		RoomID: roomID,
+		Data: map[string]any{
+			"id":        chatID,
+			"room_id":   roomID,
+			"purged_by": adminID,
+			// balasan yang sudah dihapus ikut terbuang bersama root-nya
+			"reply_ids": replyIDs,
+		},
+	})
+	return nil
 }
 
-func (u *chatUsecase) DeleteChat(roomID, userID uint) error {
+func (u *chatUsecase) AddReaction(roomID, chatID, userID uint, emoji string) error {
+	if err := u.checkReaction(roomID, chatID, userID, emoji); err != nil {
+		return err
+	}
+
+	added, err := u.reactionRepo.AddReaction(chatID, userID, emoji)
+	if err != nil {
+		return err
+	}
+
+	if added {
+		u.publishReaction(realtime.EventReactionAdded, roomID, chatID, userID, emoji)
+	}
+	return nil
+}
+
+func (u *chatUsecase) RemoveReaction(roomID, chatID, userID uint, emoji string) error {
+	if err := u.checkReaction(roomID, chatID, userID, emoji); err != nil {
+		return err
+	}
+
+	removed, err := u.reactionRepo.RemoveReaction(chatID, userID, emoji)
+	if err != nil {
+		return err
+	}
+
+	if removed {
+		u.publishReaction(realtime.EventReactionRemoved, roomID, chatID, userID, emoji)
+	}
+	return nil
+}
+
+func (u *chatUsecase) checkReaction(roomID, chatID, userID uint, emoji string) error {
+	if emoji == "" || len(emoji) > maxEmojiLength || strings.ContainsAny(emoji, " \t\r\n") {
+		return utils.ErrBadRequest
+	}
+
 	exist, err := u.roomChatRepo.IsUserInRoom(roomID, userID)
 	if !exist {
 		return utils.ErrUnauthorized
@@ -90,13 +533,105 @@ func (u *chatUsecase) DeleteChat(roomID, userID uint) error {
 		return err
 	}
 
+	chat, err := u.getChat(roomID, chatID)
+	if err != nil {
+		return err
+	}
+	if chat.DeletedAt != nil {
+		return utils.ErrChatNotFound
+	}
+	return nil
+}
+
+func (u *chatUsecase) publishReaction(eventType string, roomID, chatID, userID uint, emoji string) {
+	u.broadcaster.Publish(realtime.Event{
+		Type:   eventType,
+		RoomID: roomID,
+		Data: map[string]any{
+			"chat_id": chatID,
+			"room_id": roomID,
+			"user_id": userID,
+			"emoji":   emoji,
+		},
+	})
+}
+
+func (u *chatUsecase) MarkRead(roomID, chatID, userID uint) error {
+	exist, err := u.roomChatRepo.IsUserInRoom(roomID, userID)
+	if !exist {
+		return utils.ErrUnauthorized
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := u.getChat(roomID, chatID); err != nil {
+		return err
+	}
+
+	moved, err := u.roomChatRepo.UpdateLastRead(roomID, userID, chatID)
+	if err != nil {
+		return err
+	}
+
+	if moved {
+		u.broadcaster.Publish(realtime.Event{
+			Type:   realtime.EventReadUpdated,
+			RoomID: roomID,
+			Data: map[string]any{
+				"room_id":           roomID,
+				"user_id":           userID,
+				"last_read_chat_id": chatID,
+			},
+		})
+	}
+	return nil
+}
+
+func (u *chatUsecase) GetSeenBy(roomID, chatID, userID uint) ([]response.SeenByResponse, error) {
+	exist, err := u.roomChatRepo.IsUserInRoom(roomID, userID)
+	if !exist {
+		return nil, utils.ErrUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	chat, err := u.getChat(roomID, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := u.roomChatRepo.CountMembers(roomID)
+	if err != nil {
+		return nil, err
+	}
+	if count > maxSeenByMembers {
+		return nil, utils.ErrRoomTooLarge
+	}
+
+	var senderID uint
+	if chat.SenderID != nil {
+		senderID = *chat.SenderID
+	}
+	return u.roomChatRepo.GetSeenBy(roomID, chatID, senderID)
+}
+
+func (u *chatUsecase) getChat(roomID, chatID uint) (*model.Chat, error) {
 	existroom, err := u.roomChatRepo.IsRoomExist(roomID)
 	if !existroom {
-		return utils.ErrRoomNotFound
+		return nil, utils.ErrRoomNotFound
 	}
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return u.chatRepo.DeleteChat(roomID, userID)
 
+	chat, err := u.chatRepo.GetChatByID(roomID, chatID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrChatNotFound
+		}
+		return nil, err
+	}
+	return chat, nil
 }
@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"chat/internal/repository"
+	"chat/model"
+	"chat/response"
+	"chat/utils"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type EmailUsecase interface {
+	GetEmails(status string, limit int) ([]response.EmailResponse, error)
+	ResendEmail(id uint) error
+}
+
+type emailUsecase struct {
+	emailRepo repository.EmailRepository
+}
+
+func NewEmailUsecase(emailRepo repository.EmailRepository) EmailUsecase {
+	return &emailUsecase{emailRepo}
+}
+
+const (
+	defaultEmailLimit = 50
+	maxEmailLimit     = 200
+)
+
+func (u *emailUsecase) GetEmails(status string, limit int) ([]response.EmailResponse, error) {
+	switch status {
+	case "", model.EmailPending, model.EmailSending, model.EmailSent, model.EmailDead:
+	default:
+		return nil, utils.ErrBadRequest
+	}
+	if limit <= 0 {
+		limit = defaultEmailLimit
+	}
+	limit = min(limit, maxEmailLimit)
+
+	emails, err := u.emailRepo.GetEmails(status, limit)
+	if err != nil {
+		return nil, utils.ErrInternal
+	}
+
+	res := make([]response.EmailResponse, 0, len(emails))
+	for _, email := range emails {
+		res = append(res, response.EmailResponse{
+			ID:            email.ID,
+			To:            email.Recipient,
+			Subject:       email.Subject,
+			Status:        email.Status,
+			Attempts:      email.Attempts,
+			LastError:     email.LastError,
+			NextAttemptAt: email.NextAttemptAt,
+			SentAt:        email.SentAt,
+			CreatedAt:     email.CreatedAt,
+		})
+	}
+	return res, nil
+}
+
+// ResendEmail puts a dead email back in the queue with a fresh set of attempts
+func (u *emailUsecase) ResendEmail(id uint) error {
+	requeued, err := u.emailRepo.Requeue(id)
+	if err != nil {
+		return utils.ErrInternal
+	}
+	if requeued {
+		return nil
+	}
+
+	if _, err := u.emailRepo.GetEmailByID(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.ErrEmailNotFound
+		}
+		return utils.ErrInternal
+	}
+	return utils.ErrEmailNotDead
+}
@@ -0,0 +1,106 @@
+package usecase
+
+import (
+	"chat/response"
+	"chat/utils/logger"
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	HealthOK          = "ok"
+	HealthDegraded    = "degraded"
+	HealthUnavailable = "unavailable"
+	HealthFailed      = "failed"
+
+	healthCheckTimeout = 2 * time.Second
+)
+
+// HealthCheck satu dependency yang dicek /readyz. Optional untuk dependency yang boleh mati
+// tanpa instance harus dikeluarkan dari load balancer
+type HealthCheck struct {
+	Name     string
+	Optional bool
+	Check    func(ctx context.Context) error
+}
+
+type HealthUsecase interface {
+	Live() response.HealthResponse
+	Ready(ctx context.Context) (response.HealthResponse, bool)
+}
+
+type healthUsecase struct {
+	started time.Time
+	checks  []HealthCheck
+}
+
+func NewHealthUsecase(checks ...HealthCheck) HealthUsecase {
+	return &healthUsecase{
+		started: time.Now(),
+		checks:  checks,
+	}
+}
+
+// Live never touches a dependency, a restart wouldn't fix those
+func (u *healthUsecase) Live() response.HealthResponse {
+	return response.HealthResponse{
+		Status: HealthOK,
+		Uptime: time.Since(u.started).Round(time.Second).String(),
+	}
+}
+
+// Ready runs every check concurrently, ready is false when a required check failed
+func (u *healthUsecase) Ready(ctx context.Context) (response.HealthResponse, bool) {
+	results := make([]response.HealthCheckResponse, len(u.checks))
+
+	var wg sync.WaitGroup
+	for i, check := range u.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runHealthCheck(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	res := response.HealthResponse{
+		Status: HealthOK,
+		Checks: make(map[string]response.HealthCheckResponse, len(u.checks)),
+	}
+	ready := true
+	for i, check := range u.checks {
+		result := results[i]
+		res.Checks[check.Name] = result
+		if result.Status == HealthOK {
+			continue
+		}
+		if check.Optional {
+			if ready {
+				res.Status = HealthDegraded
+			}
+			continue
+		}
+		ready = false
+		res.Status = HealthUnavailable
+	}
+	return res, ready
+}
+
+func runHealthCheck(ctx context.Context, check HealthCheck) response.HealthCheckResponse {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Check(ctx)
+	result := response.HealthCheckResponse{
+		Status:    HealthOK,
+		LatencyMS: time.Since(start).Milliseconds(),
+		Optional:  check.Optional,
+	}
+	if err != nil {
+		result.Status = HealthFailed
+		logger.FromContext(ctx).Warn("health check failed", "check", check.Name, "optional", check.Optional, "error", err)
+	}
+	return result
+}
@@ -1,10 +1,14 @@
 package usecase
 
 import (
+	"chat/internal/realtime"
 	"chat/internal/repository"
 	"chat/model"
 	"chat/response"
 	"chat/utils"
+	"errors"
+
+	"gorm.io/gorm"
 )
 
 type RoomChatUseCase interface {
@@ -13,9 +17,11 @@ type RoomChatUseCase interface {
 	CreateRoom(userID uint, roomName string, roomDesc string) error
 	DeleteRoom(roomID, adminID uint) error
 	UpdateRoom(roomID, adminID uint, desc, name string) error
+	GetOrCreateDirectRoom(userID, peerID uint) (*response.GetGroupByUserIdResponse, bool, error)
 
 	//room member
 	GetRoomMember(roomID uint) ([]response.RoomMemberResponse, error)
+	IsUserInRoom(roomID, userID uint) (bool, error)
 	Addmembers(roomID, adminID uint, targetIDS []uint) error
 	DeleteMembersByAdmin(roomID, adminID uint, targetIDS []uint) error
 	LeaveRoom(roomID, userID, targetID uint) error
@@ -23,10 +29,11 @@ type RoomChatUseCase interface {
 
 type roomChatUseCase struct {
 	roomChatRepo repository.RoomChatRepository
+	broadcaster  realtime.Broadcaster
 }
 
-func NewRoomChatUseCase(roomChatRepo repository.RoomChatRepository) RoomChatUseCase {
-	return &roomChatUseCase{roomChatRepo}
+func NewRoomChatUseCase(roomChatRepo repository.RoomChatRepository, broadcaster realtime.Broadcaster) RoomChatUseCase {
+	return &roomChatUseCase{roomChatRepo, broadcaster}
 }
 
 func (u *roomChatUseCase) GetRoomChatByID(roomId uint) (*response.GetGroupByIdResponse, error) {
@@ -37,6 +44,53 @@ func (u *roomChatUseCase) GetRoomChatByID(roomId uint) (*response.GetGroupByIdRe
 	return u.roomChatRepo.GetRoomChatByID(roomId)
 }
 
+func (u *roomChatUseCase) GetOrCreateDirectRoom(userID, peerID uint) (*response.GetGroupByUserIdResponse, bool, error) {
+	if userID == peerID {
+		return nil, false, utils.ErrBadRequest
+	}
+
+	room, created, err := u.roomChatRepo.GetOrCreateDirectRoom(userID, peerID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, utils.ErrUserNotFound
+		}
+		return nil, false, err
+	}
+
+	if created {
+		u.broadcaster.JoinRoom(room.ID, userID, peerID)
+		u.broadcaster.Publish(realtime.Event{
+			Type:   realtime.EventRoomCreated,
+			RoomID: room.ID,
+			Data: map[string]any{
+				"room_id":  room.ID,
+				"type":     room.Type,
+				"user_ids": []uint{userID, peerID},
+			},
+		})
+	}
+
+	return &response.GetGroupByUserIdResponse{
+		ID:     room.ID,
+		Name:   room.Name,
+		Desc:   room.Desc,
+		Type:   room.Type,
+		PeerID: &peerID,
+	}, created, nil
+}
+
+// checkGroupRoom rejects member and room management on direct rooms
+func (u *roomChatUseCase) checkGroupRoom(roomID uint) error {
+	direct, err := u.roomChatRepo.IsDirectRoom(roomID)
+	if err != nil {
+		return err
+	}
+	if direct {
+		return utils.ErrDirectRoom
+	}
+	return nil
+}
+
 func (u *roomChatUseCase) GetGroupsByUserID(userID uint) ([]response.GetGroupByUserIdResponse, error) {
 	return u.roomChatRepo.GetRoomChatsByUserID(userID)
 }
@@ -45,10 +99,16 @@ func (u *roomChatUseCase) CreateRoom(userID uint, roomName string, roomDesc stri
 	roomChat := &model.RoomChat{
 		Name:      roomName,
 		Desc:      roomDesc,
+		Type:      model.RoomTypeGroup,
 		CreatorID: userID,
 	}
 
-	return u.roomChatRepo.CreateRoomChat(roomChat, userID)
+	if err := u.roomChatRepo.CreateRoomChat(roomChat, userID); err != nil {
+		return err
+	}
+
+	u.broadcaster.JoinRoom(roomChat.ID, userID)
+	return nil
 }
 
 func (u *roomChatUseCase) DeleteRoom(roomID uint, adminID uint) error {
@@ -69,7 +129,17 @@ func (u *roomChatUseCase) DeleteRoom(roomID uint, adminID uint) error {
 		return err
 	}
 
-	return u.roomChatRepo.DeleteRoom(roomID)
+	if err := u.roomChatRepo.DeleteRoom(roomID); err != nil {
+		return err
+	}
+
+	u.broadcaster.Publish(realtime.Event{
+		Type:   realtime.EventRoomDeleted,
+		RoomID: roomID,
+		Data:   map[string]any{"room_id": roomID},
+	})
+	u.broadcaster.CloseRoom(roomID)
+	return nil
 }
 func (u *roomChatUseCase) UpdateRoom(roomID, adminID uint, desc, name string) error {
 	existroom, err := u.roomChatRepo.IsRoomExist(roomID)
@@ -80,6 +150,10 @@ func (u *roomChatUseCase) UpdateRoom(roomID, adminID uint, desc, name string) er
 		return err
 	}
 
+	if err := u.checkGroupRoom(roomID); err != nil {
+		return err
+	}
+
 	exist, err := u.roomChatRepo.IsUserInRoom(roomID, adminID)
 	if !exist {
 		return utils.ErrUnauthorized
@@ -96,7 +170,20 @@ func (u *roomChatUseCase) UpdateRoom(roomID, adminID uint, desc, name string) er
 		return err
 	}
 
-	return u.roomChatRepo.UpdateRoom(roomID, name, desc)
+	if err := u.roomChatRepo.UpdateRoom(roomID, name, desc); err != nil {
+		return err
+	}
+
+	u.broadcaster.Publish(realtime.Event{
+		Type:   realtime.EventRoomUpdated,
+		RoomID: roomID,
+		Data: map[string]any{
+			"room_id": roomID,
+			"name":    name,
+			"desc":    desc,
+		},
+	})
+	return nil
 }
 
 // room member
@@ -108,6 +195,18 @@ func (u *roomChatUseCase) GetRoomMember(roomID uint) ([]response.RoomMemberRespo
 	return u.roomChatRepo.GetRoomMember(roomID)
 }
 
+// IsUserInRoom is false without an error for a room that doesn't exist too
+func (u *roomChatUseCase) IsUserInRoom(roomID, userID uint) (bool, error) {
+	member, err := u.roomChatRepo.IsUserInRoom(roomID, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, utils.ErrInternal
+	}
+	return member, nil
+}
+
 func (u *roomChatUseCase) Addmembers(roomID, adminID uint, targetIDS []uint) error {
 	roomexist, err := u.roomChatRepo.IsRoomExist(roomID)
 	if !roomexist {
@@ -117,6 +216,10 @@ func (u *roomChatUseCase) Addmembers(roomID, adminID uint, targetIDS []uint) err
 		return err
 	}
 
+	if err := u.checkGroupRoom(roomID); err != nil {
+		return err
+	}
+
 	exist, err := u.roomChatRepo.IsUserInRoom(roomID, adminID)
 	if !exist {
 		return utils.ErrUnauthorized
@@ -137,6 +240,17 @@ func (u *roomChatUseCase) Addmembers(roomID, adminID uint, targetIDS []uint) err
 		return err
 	}
 
+	// join first so the new members get their own member.added event
+	u.broadcaster.JoinRoom(roomID, targetIDS...)
+	u.broadcaster.Publish(realtime.Event{
+		Type:   realtime.EventMemberAdded,
+		RoomID: roomID,
+		Data: map[string]any{
+			"room_id":  roomID,
+			"user_ids": targetIDS,
+			"by":       adminID,
+		},
+	})
 	return nil
 }
 
@@ -149,6 +263,10 @@ func (u *roomChatUseCase) DeleteMembersByAdmin(roomID, adminID uint, targetIDS [
 		return err
 	}
 
+	if err := u.checkGroupRoom(roomID); err != nil {
+		return err
+	}
+
 	exist, err := u.roomChatRepo.IsUserInRoom(roomID, adminID)
 	if !exist {
 		return utils.ErrUnauthorized
@@ -165,8 +283,22 @@ func (u *roomChatUseCase) DeleteMembersByAdmin(roomID, adminID uint, targetIDS [
 		return err
 	}
 
-	return u.roomChatRepo.DeleteMembersByAdmin(roomID, targetIDS, adminID)
+	if err := u.roomChatRepo.DeleteMembersByAdmin(roomID, targetIDS, adminID); err != nil {
+		return err
+	}
 
+	// publish before leaving so the kicked members are told too
+	u.broadcaster.Publish(realtime.Event{
+		Type:   realtime.EventMemberKicked,
+		RoomID: roomID,
+		Data: map[string]any{
+			"room_id":  roomID,
+			"user_ids": targetIDS,
+			"by":       adminID,
+		},
+	})
+	u.broadcaster.LeaveRoom(roomID, targetIDS...)
+	return nil
 }
 
 func (u *roomChatUseCase) LeaveRoom(roomID, userID, targetID uint) error {
@@ -178,6 +310,10 @@ func (u *roomChatUseCase) LeaveRoom(roomID, userID, targetID uint) error {
 		return err
 	}
 
+	if err := u.checkGroupRoom(roomID); err != nil {
+		return err
+	}
+
 	exist, err := u.roomChatRepo.IsUserInRoom(roomID, userID)
 	if !exist {
 		return utils.ErrUnauthorized
@@ -186,5 +322,18 @@ func (u *roomChatUseCase) LeaveRoom(roomID, userID, targetID uint) error {
 		return err
 	}
 
-	return u.roomChatRepo.LeaveRoom(roomID, userID, targetID)
+	if err := u.roomChatRepo.LeaveRoom(roomID, userID, targetID); err != nil {
+		return err
+	}
+
+	u.broadcaster.LeaveRoom(roomID, targetID)
+	u.broadcaster.Publish(realtime.Event{
+		Type:   realtime.EventMemberLeft,
+		RoomID: roomID,
+		Data: map[string]any{
+			"room_id": roomID,
+			"user_id": targetID,
+		},
+	})
+	return nil
 }
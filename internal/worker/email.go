@@ -0,0 +1,142 @@
+package worker
+
+import (
+	"chat/internal/mail"
+	"chat/internal/repository"
+	"chat/model"
+	"chat/utils/metrics"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	emailPollInterval = 5 * time.Second
+	// a worker that holds an email longer than this is assumed dead and the email is sent again
+	emailLease = 2 * time.Minute
+
+	emailBaseBackoff = 30 * time.Second
+	emailMaxBackoff  = time.Hour
+)
+
+// EmailDispatcher drains the outbox table with a pool of workers. Failed sends are retried
+// with exponential backoff and dead-lettered after maxAttempts
+type EmailDispatcher struct {
+	repo        repository.EmailRepository
+	mailer      mail.Mailer
+	wake        <-chan struct{}
+	workers     int
+	maxAttempts int
+}
+
+// NewEmailDispatcher sends through mailer, wake may be nil, then only polling picks up new mail
+func NewEmailDispatcher(repo repository.EmailRepository, mailer mail.Mailer, wake <-chan struct{}, workers, maxAttempts int) *EmailDispatcher {
+	return &EmailDispatcher{
+		repo:        repo,
+		mailer:      mailer,
+		wake:        wake,
+		workers:     max(workers, 1),
+		maxAttempts: max(maxAttempts, 1),
+	}
+}
+
+// Run blocks until ctx is done, emails already handed to a worker are finished first
+func (d *EmailDispatcher) Run(ctx context.Context) {
+	jobs := make(chan model.OutboundEmail)
+	var wg sync.WaitGroup
+	for range d.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for email := range jobs {
+				d.deliver(email)
+			}
+		}()
+	}
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	ticker := time.NewTicker(emailPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		full := d.dispatch(ctx, jobs)
+		if full {
+			// there may be more due right away
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// dispatch reports whether it claimed a full batch
+func (d *EmailDispatcher) dispatch(ctx context.Context, jobs chan<- model.OutboundEmail) bool {
+	batch := d.workers * 4
+	emails, err := d.repo.ClaimDue(batch, emailLease)
+	if err != nil {
+		log.Printf("❌ Failed to claim outgoing emails: %v", err)
+		return false
+	}
+
+	for _, email := range emails {
+		select {
+		case jobs <- email:
+		case <-ctx.Done():
+			// not sent, the lease runs out and the next run picks it up again
+			return false
+		}
+	}
+	return len(emails) == batch
+}
+
+func (d *EmailDispatcher) deliver(email model.OutboundEmail) {
+	err := d.mailer.Send(mail.Message{
+		To:      email.Recipient,
+		Subject: email.Subject,
+		HTML:    email.HTML,
+		Text:    email.Text,
+	})
+	if err == nil {
+		metrics.EmailSent(metrics.EmailSuccess)
+		if err := d.repo.MarkSent(email.ID); err != nil {
+			log.Printf("❌ Email %d sent but not marked: %v", email.ID, err)
+		}
+		return
+	}
+
+	attempts := email.Attempts + 1
+	dead := attempts >= d.maxAttempts
+	if dead {
+		metrics.EmailSent(metrics.EmailDead)
+		log.Printf("💀 Email %d to %s dead after %d attempts: %v", email.ID, email.Recipient, attempts, err)
+	} else {
+		metrics.EmailSent(metrics.EmailFailure)
+		log.Printf("⚠ Email %d to %s failed (attempt %d/%d): %v", email.ID, email.Recipient, attempts, d.maxAttempts, err)
+	}
+
+	if err := d.repo.MarkFailed(email.ID, attempts, err.Error(), time.Now().Add(backoff(attempts)), dead); err != nil {
+		log.Printf("❌ Failed to record email %d failure: %v", email.ID, err)
+	}
+}
+
+// backoff doubles from emailBaseBackoff per attempt, capped at emailMaxBackoff
+func backoff(attempts int) time.Duration {
+	delay := emailBaseBackoff
+	for i := 1; i < attempts && delay < emailMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, emailMaxBackoff)
+}
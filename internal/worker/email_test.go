@@ -0,0 +1,186 @@
+package worker
+
+import (
+	"chat/internal/mail"
+	"chat/internal/migration"
+	"chat/internal/repository"
+	"chat/migrations"
+	"chat/model"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_pragma=foreign_keys(1)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	migs, err := migration.Load(migrations.FS, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migration.NewMigrator(db, migs).Up(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// claim makes the email due again and leases it like a worker would
+func claim(t *testing.T, db *gorm.DB, repo repository.EmailRepository, id uint) model.OutboundEmail {
+	t.Helper()
+
+	if err := db.Model(&model.OutboundEmail{}).Where("id = ?", id).Update("next_attempt_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+	emails, err := repo.ClaimDue(10, emailLease)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(emails) != 1 || emails[0].ID != id {
+		t.Fatalf("claimed %+v, want email %d", emails, id)
+	}
+	return emails[0]
+}
+
+func TestEmailDispatcher_RetryThenDead(t *testing.T) {
+	db := newTestDB(t)
+	repo := repository.NewEmailRepository(db)
+	mailer := mail.NewMemoryMailer()
+	mailer.Err = errors.New("smtp down")
+	d := NewEmailDispatcher(repo, mailer, nil, 1, 3)
+
+	email := &model.OutboundEmail{Recipient: "budi@example.com", Subject: "halo", Text: "halo"}
+	if err := repo.Enqueue(email); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		attempt int
+		status  string
+	}{
+		{1, model.EmailPending},
+		{2, model.EmailPending},
+		{3, model.EmailDead},
+	}
+	for _, tt := range tests {
+		before := time.Now()
+		d.deliver(claim(t, db, repo, email.ID))
+
+		got, err := repo.GetEmailByID(email.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != tt.status || got.Attempts != tt.attempt {
+			t.Fatalf("attempt %d: status %s attempts %d, want %s %d", tt.attempt, got.Status, got.Attempts, tt.status, tt.attempt)
+		}
+		if got.LastError != "smtp down" {
+			t.Errorf("attempt %d: last_error %q", tt.attempt, got.LastError)
+		}
+		if !got.NextAttemptAt.After(before.Add(backoff(tt.attempt) - time.Second)) {
+			t.Errorf("attempt %d: next attempt at %s, want backoff of %s", tt.attempt, got.NextAttemptAt, backoff(tt.attempt))
+		}
+	}
+
+	// dead email tidak diambil worker lagi walaupun sudah jatuh tempo
+	if err := db.Model(&model.OutboundEmail{}).Where("id = ?", email.ID).Update("next_attempt_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if emails, err := repo.ClaimDue(10, emailLease); err != nil || len(emails) != 0 {
+		t.Fatalf("ClaimDue after dead = %+v, %v, want nothing", emails, err)
+	}
+	if sent := mailer.Sent(); len(sent) != 0 {
+		t.Errorf("failed sends recorded %d messages", len(sent))
+	}
+
+	// resend dari admin memberi jatah percobaan baru
+	if ok, err := repo.Requeue(email.ID); err != nil || !ok {
+		t.Fatalf("Requeue = %v, %v", ok, err)
+	}
+	mailer.Err = nil
+	d.deliver(claim(t, db, repo, email.ID))
+
+	got, err := repo.GetEmailByID(email.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != model.EmailSent || got.SentAt == nil || got.LastError != "" {
+		t.Errorf("after resend: status %s sent_at %v last_error %q", got.Status, got.SentAt, got.LastError)
+	}
+	if sent := mailer.Sent(); len(sent) != 1 || sent[0].To != "budi@example.com" {
+		t.Errorf("sent %+v", sent)
+	}
+}
+
+func TestEmailDispatcher_Run(t *testing.T) {
+	db := newTestDB(t)
+	repo := repository.NewEmailRepository(db)
+	queue := mail.NewQueueMailer(repo)
+	mailer := mail.NewMemoryMailer()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewEmailDispatcher(repo, mailer, queue.Enqueued(), 2, 3).Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	if err := queue.Send(mail.Message{To: "siti@example.com", Subject: "halo", Text: "halo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// wake membangunkan worker sebelum poll berikutnya
+	deadline := time.Now().Add(emailPollInterval / 2)
+	for len(mailer.Sent()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("queued email was not sent before the next poll")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	emails, err := repo.GetEmails(model.EmailSent, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(emails) != 1 || emails[0].Attempts != 1 {
+		t.Errorf("sent emails %+v, want one with 1 attempt", emails)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{50, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
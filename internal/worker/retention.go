@@ -0,0 +1,50 @@
+package worker
+
+import (
+	"chat/internal/repository"
+	"context"
+	"log"
+	"time"
+)
+
+// TombstoneCleaner physically removes soft deleted chats once they are older than retention
+type TombstoneCleaner struct {
+	chatRepo  repository.ChatRepository
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewTombstoneCleaner(chatRepo repository.ChatRepository, retention, interval time.Duration) *TombstoneCleaner {
+	return &TombstoneCleaner{
+		chatRepo:  chatRepo,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Run blocks until ctx is done, sweeping once at start and then every interval
+func (c *TombstoneCleaner) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.sweep()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *TombstoneCleaner) sweep() {
+	purged, err := c.chatRepo.PurgeDeletedBefore(time.Now().Add(-c.retention))
+	if err != nil {
+		log.Printf("❌ Failed to purge deleted chats: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("🧹 Purged %d deleted chats", purged)
+	}
+}
@@ -0,0 +1,7 @@
+// Package migrations berisi file SQL per driver, di-embed supaya binary server tahu versi schema yang dia butuhkan
+package migrations
+
+import "embed"
+
+//go:embed */*.sql
+var FS embed.FS
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS outbound_emails;
+DROP TABLE IF EXISTS password_resets;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS mentions;
+DROP TABLE IF EXISTS reactions;
+DROP TABLE IF EXISTS chat_revisions;
+DROP TABLE IF EXISTS chats;
+DROP TABLE IF EXISTS room_members;
+DROP TABLE IF EXISTS room_chats;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,145 @@
+-- schema awal untuk database baru. Tabel yang sudah ada dilewati (IF NOT EXISTS) tanpa ditambah kolomnya,
+-- database mysql lama dari AutoMigrate dilengkapi oleh 0004_upgrade_automigrate_schema.
+-- mysql tidak punya CREATE INDEX IF NOT EXISTS, jadi index ditulis di dalam CREATE TABLE
+
+CREATE TABLE IF NOT EXISTS users (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    username VARCHAR(191) NOT NULL,
+    email VARCHAR(191) NOT NULL,
+    password LONGTEXT NOT NULL,
+    created_at DATETIME(3) NULL,
+    is_verified BOOLEAN DEFAULT false,
+    totp_secret VARCHAR(64),
+    totp_enabled BOOLEAN DEFAULT false,
+    totp_last_step BIGINT DEFAULT 0,
+    recovery_codes TEXT,
+    CONSTRAINT uni_users_username UNIQUE (username),
+    CONSTRAINT uni_users_email UNIQUE (email)
+);
+
+CREATE TABLE IF NOT EXISTS room_chats (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    name LONGTEXT NOT NULL,
+    `desc` LONGTEXT,
+    type VARCHAR(16) NOT NULL DEFAULT 'group',
+    creator_id BIGINT UNSIGNED NOT NULL,
+    created_at DATETIME(3) NULL,
+    direct_key VARCHAR(64),
+    UNIQUE INDEX idx_room_chats_direct_key (direct_key),
+    CONSTRAINT fk_room_chats_creator FOREIGN KEY (creator_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS room_members (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    room_id BIGINT UNSIGNED NOT NULL,
+    user_id BIGINT UNSIGNED,
+    role LONGTEXT NOT NULL,
+    last_read_chat_id BIGINT UNSIGNED,
+    UNIQUE INDEX idx_room_user (room_id, user_id),
+    CONSTRAINT fk_room_chats_room_members FOREIGN KEY (room_id) REFERENCES room_chats(id) ON DELETE CASCADE,
+    CONSTRAINT fk_room_members_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS chats (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    room_id BIGINT UNSIGNED NOT NULL,
+    sender_id BIGINT UNSIGNED,
+    parent_id BIGINT UNSIGNED,
+    message LONGTEXT NOT NULL,
+    created_at DATETIME(3) NULL,
+    edited_at DATETIME(3) NULL,
+    deleted_at DATETIME(3) NULL,
+    deleted_by BIGINT UNSIGNED,
+    INDEX idx_chats_deleted_at (deleted_at),
+    INDEX idx_chats_parent_id (parent_id),
+    INDEX idx_chats_room_id_id (room_id, id),
+    CONSTRAINT fk_room_chats_chats FOREIGN KEY (room_id) REFERENCES room_chats(id) ON DELETE CASCADE,
+    CONSTRAINT fk_chats_sender FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE SET NULL,
+    CONSTRAINT fk_chats_replies FOREIGN KEY (parent_id) REFERENCES chats(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS chat_revisions (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    chat_id BIGINT UNSIGNED NOT NULL,
+    message LONGTEXT NOT NULL,
+    written_at DATETIME(3) NOT NULL,
+    created_at DATETIME(3) NULL,
+    INDEX idx_chat_revisions_chat_id (chat_id),
+    CONSTRAINT fk_chats_revisions FOREIGN KEY (chat_id) REFERENCES chats(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS reactions (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    chat_id BIGINT UNSIGNED NOT NULL,
+    user_id BIGINT UNSIGNED NOT NULL,
+    emoji VARCHAR(64) NOT NULL,
+    created_at DATETIME(3) NULL,
+    UNIQUE INDEX idx_chat_user_emoji (chat_id, user_id, emoji),
+    CONSTRAINT fk_chats_reactions FOREIGN KEY (chat_id) REFERENCES chats(id) ON DELETE CASCADE,
+    CONSTRAINT fk_reactions_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS mentions (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    chat_id BIGINT UNSIGNED NOT NULL,
+    room_id BIGINT UNSIGNED NOT NULL,
+    user_id BIGINT UNSIGNED NOT NULL,
+    kind VARCHAR(16) NOT NULL,
+    span_start BIGINT NOT NULL,
+    span_length BIGINT NOT NULL,
+    read_at DATETIME(3) NULL,
+    created_at DATETIME(3) NULL,
+    INDEX idx_mentions_user_read (user_id, read_at),
+    INDEX idx_mentions_chat_id (chat_id),
+    CONSTRAINT fk_chats_mentions FOREIGN KEY (chat_id) REFERENCES chats(id) ON DELETE CASCADE,
+    CONSTRAINT fk_mentions_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id VARCHAR(64) PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    revoked_at DATETIME(3) NULL,
+    created_at DATETIME(3) NULL,
+    INDEX idx_sessions_user_id (user_id),
+    CONSTRAINT fk_sessions_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    session_id VARCHAR(64) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    expires_at DATETIME(3) NOT NULL,
+    used_at DATETIME(3) NULL,
+    created_at DATETIME(3) NULL,
+    UNIQUE INDEX idx_refresh_tokens_token_hash (token_hash),
+    INDEX idx_refresh_tokens_session_id (session_id),
+    CONSTRAINT fk_sessions_refresh_tokens FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS password_resets (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    expires_at DATETIME(3) NOT NULL,
+    used_at DATETIME(3) NULL,
+    created_at DATETIME(3) NULL,
+    INDEX idx_password_resets_user_id (user_id),
+    UNIQUE INDEX idx_password_resets_token_hash (token_hash),
+    CONSTRAINT fk_password_resets_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS outbound_emails (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    recipient VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    html TEXT,
+    text TEXT,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts BIGINT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at DATETIME(3) NOT NULL,
+    sent_at DATETIME(3) NULL,
+    created_at DATETIME(3) NULL,
+    updated_at DATETIME(3) NULL,
+    INDEX idx_outbound_emails_due (status, next_attempt_at)
+);
@@ -0,0 +1 @@
+-- huruf besar/kecil email lama tidak disimpan, tidak ada yang bisa dikembalikan
@@ -0,0 +1,3 @@
+-- batas kode 2FA salah saat login
+ALTER TABLE users ADD COLUMN mfa_failures INT DEFAULT 0;
+ALTER TABLE users ADD COLUMN mfa_locked_at DATETIME(3) NULL;
@@ -0,0 +1 @@
+-- tidak ada yang di-rollback, di database baru kolom dan index ini milik 0001
@@ -0,0 +1,99 @@
+-- database yang dibuat AutoMigrate sebelum ada migration: tabelnya sudah ada jadi 0001 melewatinya
+-- dan kolom/index barunya tidak pernah dibuat. mysql tidak punya ADD COLUMN IF NOT EXISTS,
+-- jadi tiap ALTER dicek dulu ke information_schema dan diganti DO 0 kalau sudah ada (database baru)
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'users' AND column_name = 'totp_secret') = 0,
+    'ALTER TABLE users ADD COLUMN totp_secret VARCHAR(64)', 'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'users' AND column_name = 'totp_enabled') = 0,
+    'ALTER TABLE users ADD COLUMN totp_enabled BOOLEAN DEFAULT false', 'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'users' AND column_name = 'totp_last_step') = 0,
+    'ALTER TABLE users ADD COLUMN totp_last_step BIGINT DEFAULT 0', 'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'users' AND column_name = 'recovery_codes') = 0,
+    'ALTER TABLE users ADD COLUMN recovery_codes TEXT', 'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'room_chats' AND column_name = 'type') = 0,
+    'ALTER TABLE room_chats ADD COLUMN type VARCHAR(16) NOT NULL DEFAULT ''group''', 'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'room_chats' AND column_name = 'direct_key') = 0,
+    'ALTER TABLE room_chats ADD COLUMN direct_key VARCHAR(64)', 'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'room_members' AND column_name = 'last_read_chat_id') = 0,
+    'ALTER TABLE room_members ADD COLUMN last_read_chat_id BIGINT UNSIGNED', 'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'chats' AND column_name = 'parent_id') = 0,
+    'ALTER TABLE chats ADD COLUMN parent_id BIGINT UNSIGNED', 'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'chats' AND column_name = 'edited_at') = 0,
+    'ALTER TABLE chats ADD COLUMN edited_at DATETIME(3) NULL', 'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'chats' AND column_name = 'deleted_at') = 0,
+    'ALTER TABLE chats ADD COLUMN deleted_at DATETIME(3) NULL', 'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'chats' AND column_name = 'deleted_by') = 0,
+    'ALTER TABLE chats ADD COLUMN deleted_by BIGINT UNSIGNED', 'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = 'room_chats' AND index_name = 'idx_room_chats_direct_key') = 0,
+    'ALTER TABLE room_chats ADD UNIQUE INDEX idx_room_chats_direct_key (direct_key)', 'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = 'chats' AND index_name = 'idx_chats_deleted_at') = 0,
+    'ALTER TABLE chats ADD INDEX idx_chats_deleted_at (deleted_at)', 'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = 'chats' AND index_name = 'idx_chats_parent_id') = 0,
+    'ALTER TABLE chats ADD INDEX idx_chats_parent_id (parent_id)', 'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = 'chats' AND index_name = 'idx_chats_room_id_id') = 0,
+    'ALTER TABLE chats ADD INDEX idx_chats_room_id_id (room_id, id)', 'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.table_constraints WHERE table_schema = DATABASE() AND table_name = 'chats' AND constraint_name = 'fk_chats_replies') = 0,
+    'ALTER TABLE chats ADD CONSTRAINT fk_chats_replies FOREIGN KEY (parent_id) REFERENCES chats(id) ON DELETE CASCADE', 'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
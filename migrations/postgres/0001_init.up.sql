@@ -0,0 +1,128 @@
+-- schema awal untuk database baru. Tabel yang sudah ada dilewati (IF NOT EXISTS) tanpa ditambah kolomnya,
+-- lihat 0004_upgrade_automigrate_schema untuk database lama dari AutoMigrate
+
+CREATE TABLE IF NOT EXISTS users (
+    id BIGSERIAL PRIMARY KEY,
+    username TEXT NOT NULL UNIQUE,
+    email TEXT NOT NULL UNIQUE,
+    password TEXT NOT NULL,
+    created_at TIMESTAMPTZ,
+    is_verified BOOLEAN DEFAULT false,
+    totp_secret VARCHAR(64),
+    totp_enabled BOOLEAN DEFAULT false,
+    totp_last_step BIGINT DEFAULT 0,
+    recovery_codes TEXT
+);
+
+CREATE TABLE IF NOT EXISTS room_chats (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    "desc" TEXT,
+    type VARCHAR(16) NOT NULL DEFAULT 'group',
+    creator_id BIGINT NOT NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ,
+    direct_key VARCHAR(64)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_room_chats_direct_key ON room_chats(direct_key);
+
+CREATE TABLE IF NOT EXISTS room_members (
+    id BIGSERIAL PRIMARY KEY,
+    room_id BIGINT NOT NULL REFERENCES room_chats(id) ON DELETE CASCADE,
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    role TEXT NOT NULL,
+    last_read_chat_id BIGINT
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_room_user ON room_members(room_id, user_id);
+
+CREATE TABLE IF NOT EXISTS chats (
+    id BIGSERIAL PRIMARY KEY,
+    room_id BIGINT NOT NULL REFERENCES room_chats(id) ON DELETE CASCADE,
+    sender_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    parent_id BIGINT REFERENCES chats(id) ON DELETE CASCADE,
+    message TEXT NOT NULL,
+    created_at TIMESTAMPTZ,
+    edited_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    deleted_by BIGINT
+);
+CREATE INDEX IF NOT EXISTS idx_chats_deleted_at ON chats(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_chats_parent_id ON chats(parent_id);
+CREATE INDEX IF NOT EXISTS idx_chats_room_id_id ON chats(room_id, id);
+
+CREATE TABLE IF NOT EXISTS chat_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    chat_id BIGINT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    message TEXT NOT NULL,
+    written_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_chat_revisions_chat_id ON chat_revisions(chat_id);
+
+CREATE TABLE IF NOT EXISTS reactions (
+    id BIGSERIAL PRIMARY KEY,
+    chat_id BIGINT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    emoji VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_chat_user_emoji ON reactions(chat_id, user_id, emoji);
+
+CREATE TABLE IF NOT EXISTS mentions (
+    id BIGSERIAL PRIMARY KEY,
+    chat_id BIGINT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    room_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(16) NOT NULL,
+    span_start BIGINT NOT NULL,
+    span_length BIGINT NOT NULL,
+    read_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_mentions_user_read ON mentions(user_id, read_at);
+CREATE INDEX IF NOT EXISTS idx_mentions_chat_id ON mentions(chat_id);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id VARCHAR(64) PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    session_id VARCHAR(64) NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens(token_hash);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session_id ON refresh_tokens(session_id);
+
+CREATE TABLE IF NOT EXISTS password_resets (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_password_resets_user_id ON password_resets(user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_password_resets_token_hash ON password_resets(token_hash);
+
+CREATE TABLE IF NOT EXISTS outbound_emails (
+    id BIGSERIAL PRIMARY KEY,
+    recipient VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    html TEXT,
+    text TEXT,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts BIGINT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_outbound_emails_due ON outbound_emails(status, next_attempt_at);
@@ -0,0 +1,3 @@
+-- email sekarang selalu disimpan lowercase dan dicocokkan dengan email = ?.
+-- gagal kalau ada dua akun yang emailnya cuma beda huruf besar/kecil, gabungkan/hapus salah satunya dulu
+UPDATE users SET email = LOWER(email) WHERE email <> LOWER(email);
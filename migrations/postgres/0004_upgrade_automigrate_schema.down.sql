@@ -0,0 +1 @@
+-- tidak ada yang di-rollback
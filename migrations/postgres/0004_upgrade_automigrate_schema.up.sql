@@ -0,0 +1,2 @@
+-- postgres dan sqlite baru didukung setelah semua kolom ada di model, jadi database lama dari AutoMigrate
+-- tidak kekurangan apa-apa. File ini ada supaya nomor versi tetap sama di semua driver
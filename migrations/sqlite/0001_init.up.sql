@@ -0,0 +1,128 @@
+-- schema awal untuk database baru. Tabel yang sudah ada dilewati (IF NOT EXISTS) tanpa ditambah kolomnya,
+-- lihat 0004_upgrade_automigrate_schema untuk database lama dari AutoMigrate
+
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username TEXT NOT NULL UNIQUE,
+    email TEXT NOT NULL UNIQUE,
+    password TEXT NOT NULL,
+    created_at DATETIME,
+    is_verified NUMERIC DEFAULT false,
+    totp_secret TEXT,
+    totp_enabled NUMERIC DEFAULT false,
+    totp_last_step INTEGER DEFAULT 0,
+    recovery_codes TEXT
+);
+
+CREATE TABLE IF NOT EXISTS room_chats (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    "desc" TEXT,
+    type TEXT NOT NULL DEFAULT 'group',
+    creator_id INTEGER NOT NULL REFERENCES users(id),
+    created_at DATETIME,
+    direct_key TEXT
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_room_chats_direct_key ON room_chats(direct_key);
+
+CREATE TABLE IF NOT EXISTS room_members (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    room_id INTEGER NOT NULL REFERENCES room_chats(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    role TEXT NOT NULL,
+    last_read_chat_id INTEGER
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_room_user ON room_members(room_id, user_id);
+
+CREATE TABLE IF NOT EXISTS chats (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    room_id INTEGER NOT NULL REFERENCES room_chats(id) ON DELETE CASCADE,
+    sender_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    parent_id INTEGER REFERENCES chats(id) ON DELETE CASCADE,
+    message TEXT NOT NULL,
+    created_at DATETIME,
+    edited_at DATETIME,
+    deleted_at DATETIME,
+    deleted_by INTEGER
+);
+CREATE INDEX IF NOT EXISTS idx_chats_deleted_at ON chats(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_chats_parent_id ON chats(parent_id);
+CREATE INDEX IF NOT EXISTS idx_chats_room_id_id ON chats(room_id, id);
+
+CREATE TABLE IF NOT EXISTS chat_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    chat_id INTEGER NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    message TEXT NOT NULL,
+    written_at DATETIME NOT NULL,
+    created_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_chat_revisions_chat_id ON chat_revisions(chat_id);
+
+CREATE TABLE IF NOT EXISTS reactions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    chat_id INTEGER NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    emoji TEXT NOT NULL,
+    created_at DATETIME
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_chat_user_emoji ON reactions(chat_id, user_id, emoji);
+
+CREATE TABLE IF NOT EXISTS mentions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    chat_id INTEGER NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    room_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    span_start INTEGER NOT NULL,
+    span_length INTEGER NOT NULL,
+    read_at DATETIME,
+    created_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_mentions_user_read ON mentions(user_id, read_at);
+CREATE INDEX IF NOT EXISTS idx_mentions_chat_id ON mentions(chat_id);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    revoked_at DATETIME,
+    created_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id TEXT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME,
+    created_at DATETIME
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens(token_hash);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session_id ON refresh_tokens(session_id);
+
+CREATE TABLE IF NOT EXISTS password_resets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME,
+    created_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_password_resets_user_id ON password_resets(user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_password_resets_token_hash ON password_resets(token_hash);
+
+CREATE TABLE IF NOT EXISTS outbound_emails (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    recipient TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    html TEXT,
+    text TEXT,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at DATETIME NOT NULL,
+    sent_at DATETIME,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_outbound_emails_due ON outbound_emails(status, next_attempt_at);
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN mfa_locked_at;
+ALTER TABLE users DROP COLUMN mfa_failures;
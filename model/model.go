@@ -12,15 +12,36 @@ type User struct {
 	Password   string    `gorm:"not null"`
 	CreatedAt  time.Time `gorm:"autoCreateTime"`
 	IsVerified bool      `gorm:"default:false"`
+
+	// 2FA, secret terisi sejak enroll tapi baru aktif setelah dikonfirmasi
+	TOTPSecret  *string `gorm:"size:64"`
+	TOTPEnabled bool    `gorm:"default:false"`
+	// time step terakhir yang dipakai, kode yang sama tidak bisa dipakai dua kali
+	TOTPLastStep int64 `gorm:"default:0"`
+	// hash recovery code dipisah koma, tiap code cuma bisa dipakai sekali
+	RecoveryCodes string `gorm:"type:text"`
+	// kode 2FA salah berturut-turut saat login, dikunci setelah batasnya tercapai
+	MFAFailures int `gorm:"default:0"`
+	// waktu terakhir dikunci, token MFA yang terbit sebelum ini hangus
+	MFALockedAt *time.Time
 }
 
+const (
+	RoomTypeGroup  = "group"
+	RoomTypeDirect = "direct"
+)
+
 type RoomChat struct {
 	ID        uint   `gorm:"primaryKey"`
 	Name      string `gorm:"not null"`
 	Desc      string
+	Type      string    `gorm:"size:16;not null;default:group"`
 	CreatorID uint      `gorm:"not null"`
 	CreatedAt time.Time `gorm:"autoCreateTime"`
 
+	// "<id kecil>:<id besar>" untuk room direct, NULL untuk group, supaya DM antar 2 user cuma ada satu
+	DirectKey *string `gorm:"size:64;uniqueIndex"`
+
 	// Relasi
 	Creator User `gorm:"foreignKey:CreatorID"`
 
@@ -35,19 +56,137 @@ type RoomMember struct {
 	UserID *uint  `gorm:"uniqueIndex:idx_room_user;constraint:OnDelete:SET NULL"`
 	Role   string `gorm:"not null"`
 
+	// chat terakhir yang sudah dibaca member ini, cuma boleh maju
+	LastReadChatID *uint
+
 	// Relasi
 	Room RoomChat `gorm:"foreignKey:RoomID"`
 	User User     `gorm:"foreignKey:UserID"`
 }
 
 type Chat struct {
-	ID        uint      `gorm:"primaryKey"`
-	RoomID    uint      `gorm:"not null;index"`
+	ID        uint      `gorm:"primaryKey;index:idx_chats_room_id_id,priority:2"`
+	RoomID    uint      `gorm:"not null;index:idx_chats_room_id_id,priority:1"`
 	SenderID  *uint     `gorm:"constraint:OnDelete:SET NULL"`
+	ParentID  *uint     `gorm:"index"`
 	Message   string    `gorm:"not null"`
 	CreatedAt time.Time `gorm:"autoCreateTime"`
+	EditedAt  *time.Time
+
+	// soft delete, bukan gorm.DeletedAt karena tombstone tetap harus ikut di list chat
+	DeletedAt *time.Time `gorm:"index"`
+	DeletedBy *uint
 
 	// Relasi
 	Room   RoomChat `gorm:"foreignKey:RoomID"`
 	Sender User     `gorm:"foreignKey:SenderID"`
+
+	// Relasi for delete cascade
+	Revisions []ChatRevision `gorm:"foreignKey:ChatID;constraint:OnDelete:CASCADE"`
+	Replies   []Chat         `gorm:"foreignKey:ParentID;constraint:OnDelete:CASCADE"`
+	Reactions []Reaction     `gorm:"foreignKey:ChatID;constraint:OnDelete:CASCADE"`
+	Mentions  []Mention      `gorm:"foreignKey:ChatID;constraint:OnDelete:CASCADE"`
+}
+
+// ChatRevision menyimpan isi chat sebelum di-edit
+type ChatRevision struct {
+	ID        uint      `gorm:"primaryKey"`
+	ChatID    uint      `gorm:"not null;index"`
+	Message   string    `gorm:"not null"`
+	WrittenAt time.Time `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// Reaction satu emoji dari satu user di satu chat
+type Reaction struct {
+	ID        uint      `gorm:"primaryKey"`
+	ChatID    uint      `gorm:"not null;uniqueIndex:idx_chat_user_emoji"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_chat_user_emoji"`
+	Emoji     string    `gorm:"size:64;not null;uniqueIndex:idx_chat_user_emoji"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+
+	// Relasi
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+// Mention satu @mention untuk satu user penerima, @room/@here jadi satu baris per member.
+// SpanStart/SpanLength dihitung dalam rune
+type Mention struct {
+	ID         uint       `gorm:"primaryKey"`
+	ChatID     uint       `gorm:"not null;index"`
+	RoomID     uint       `gorm:"not null"`
+	UserID     uint       `gorm:"not null;index:idx_mentions_user_read"`
+	Kind       string     `gorm:"size:16;not null"`
+	SpanStart  int        `gorm:"not null"`
+	SpanLength int        `gorm:"not null"`
+	ReadAt     *time.Time `gorm:"index:idx_mentions_user_read"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime"`
+
+	// Relasi
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+// Session satu login, semua refresh token hasil rotasi dari login itu satu family
+type Session struct {
+	ID        string `gorm:"primaryKey;size:64"`
+	UserID    uint   `gorm:"not null;index"`
+	RevokedAt *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+
+	// Relasi
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+
+	// Relasi for delete cascade
+	RefreshTokens []RefreshToken `gorm:"foreignKey:SessionID;constraint:OnDelete:CASCADE"`
+}
+
+// RefreshToken cuma hash-nya yang disimpan, UsedAt terisi begitu token dirotasi
+type RefreshToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	SessionID string    `gorm:"size:64;not null;index"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+
+	// Relasi
+	Session Session `gorm:"foreignKey:SessionID"`
+}
+
+// PasswordReset token reset password, cuma hash-nya yang disimpan dan cuma bisa dipakai sekali
+type PasswordReset struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+
+	// Relasi
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+const (
+	EmailPending = "pending"
+	EmailSending = "sending"
+	EmailSent    = "sent"
+	// dead letter, sudah gagal sebanyak max attempts dan tidak dicoba lagi kecuali di-resend admin
+	EmailDead = "dead"
+)
+
+// OutboundEmail antrian email keluar, dikirim worker di background
+type OutboundEmail struct {
+	ID        uint   `gorm:"primaryKey"`
+	Recipient string `gorm:"size:255;not null"`
+	Subject   string `gorm:"size:255;not null"`
+	HTML      string `gorm:"type:text"`
+	Text      string `gorm:"type:text"`
+	Status    string `gorm:"size:16;not null;default:pending;index:idx_outbound_emails_due,priority:1"`
+	Attempts  int    `gorm:"not null;default:0"`
+	LastError string `gorm:"type:text"`
+	// kapan boleh dicoba lagi, untuk status sending ini batas lease worker yang lagi kirim
+	NextAttemptAt time.Time `gorm:"not null;index:idx_outbound_emails_due,priority:2"`
+	SentAt        *time.Time
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
 }
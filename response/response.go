@@ -2,16 +2,36 @@ package response
 
 import "time"
 
+// PeerID and PeerUsername are only set for direct rooms
 type GetGroupByUserIdResponse struct {
-	ID   uint   `json:"room_id"`
-	Name string `json:"name"`
-	Desc string `json:"desc"`
+	ID           uint   `json:"room_id"`
+	Name         string `json:"name"`
+	Desc         string `json:"desc"`
+	Type         string `json:"type"`
+	PeerID       *uint  `json:"peer_id,omitempty"`
+	PeerUsername string `json:"peer_username,omitempty"`
+
+	UnreadCount int64                `json:"unread_count" gorm:"-"`
+	LastMessage *ChatPreviewResponse `json:"last_message" gorm:"-"`
+}
+
+type ChatPreviewResponse struct {
+	ID       uint      `json:"id"`
+	SenderID uint      `json:"user_id"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+}
+
+type SeenByResponse struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
 }
 
 type GetGroupByIdResponse struct {
 	ID         uint                 `json:"id"`
 	Name       string               `json:"name"`
 	Desc       string               `json:"desc"`
+	Type       string               `json:"type"`
 	CreatorID  uint                 `json:"creator_id"`
 	RoomMember []RoomMemberResponse `json:"room_members"`
 }
@@ -22,9 +42,109 @@ type RoomMemberResponse struct {
 	Role   string `json:"role"`
 }
 
+type PresenceResponse struct {
+	UserID   uint       `json:"user_id"`
+	Role     string     `json:"role"`
+	Status   string     `json:"status"`
+	LastSeen *time.Time `json:"last_seen"`
+	Typing   bool       `json:"typing"`
+}
+
 type ChatResponse struct {
+	ID        uint       `json:"id"`
+	RoomID    uint       `json:"room_id"`
+	SenderID  uint       `json:"user_id"`
+	ParentID  *uint      `json:"parent_id"`
+	Message   string     `json:"message"`
+	Time      time.Time  `json:"time"`
+	EditedAt  *time.Time `json:"edited_at"`
+	DeletedAt *time.Time `json:"deleted_at"`
+
+	// thread summary, only filled on root messages in the room listing
+	ReplyCount  int64      `json:"reply_count"`
+	LastReplyAt *time.Time `json:"last_reply_at"`
+
+	// filled by the usecase, gorm:"-" keeps Find from treating them as relations
+	Reactions []ReactionResponse `json:"reactions" gorm:"-"`
+	Mentions  []MentionResponse  `json:"mentions" gorm:"-"`
+}
+
+// Offset and Length count runes in Message. UserID is only set for kind "user"
+type MentionResponse struct {
+	Kind     string `json:"kind"`
+	UserID   *uint  `json:"user_id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Offset   int    `json:"offset"`
+	Length   int    `json:"length"`
+}
+
+type MentionInboxResponse struct {
+	ID       uint      `json:"id"`
+	ChatID   uint      `json:"chat_id"`
 	RoomID   uint      `json:"room_id"`
-	SenderID uint      `json:"user_id"`
+	Kind     string    `json:"kind"`
+	SenderID uint      `json:"sender_id"`
 	Message  string    `json:"message"`
 	Time     time.Time `json:"time"`
 }
+
+// Reacted tells whether the user asking has reacted with this emoji
+type ReactionResponse struct {
+	Emoji   string `json:"emoji"`
+	Count   int64  `json:"count"`
+	Reacted bool   `json:"reacted"`
+}
+
+// WrittenAt is when this text was posted or edited in, ReplacedAt when the next edit replaced it
+type ChatRevisionResponse struct {
+	ID         uint      `json:"id"`
+	ChatID     uint      `json:"chat_id"`
+	Message    string    `json:"message"`
+	WrittenAt  time.Time `json:"written_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+// NextCursor goes to older messages (pass it as ?before=), PrevCursor to newer ones (?after=)
+type ChatPageResponse struct {
+	Chats      []ChatResponse `json:"chats"`
+	NextCursor *uint          `json:"next_cursor"`
+	PrevCursor *uint          `json:"prev_cursor"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+type TOTPEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+}
+
+type EmailResponse struct {
+	ID            uint       `json:"id"`
+	To            string     `json:"to"`
+	Subject       string     `json:"subject"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	SentAt        *time.Time `json:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// HealthResponse status ok, degraded (cuma check opsional yang gagal) atau unavailable
+type HealthResponse struct {
+	Status string                         `json:"status"`
+	Uptime string                         `json:"uptime,omitempty"`
+	Checks map[string]HealthCheckResponse `json:"checks,omitempty"`
+}
+
+type HealthCheckResponse struct {
+	// detail error cuma di log, /readyz tidak pakai auth
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms"`
+	// check opsional yang gagal tidak membuat instance not ready
+	Optional bool `json:"optional,omitempty"`
+}
@@ -6,7 +6,22 @@ var (
 	ErrFailedRegister = errors.New("failed register")
 	ErrUnauthorized   = errors.New("unauthorized")
 	ErrRoomNotFound   = errors.New("room doesn't exist")
+	ErrChatNotFound   = errors.New("chat doesn't exist")
+	ErrHasReplies     = errors.New("message still has replies, delete it instead")
 	ErrBadRequest     = errors.New("bad request")
 	ErrInternal       = errors.New("internal server error")
-	ErrUserNotFound   = errors.New("internal server error")
+	ErrUserNotFound   = errors.New("user doesn't exist")
+	ErrDirectRoom     = errors.New("not allowed in a direct room")
+	ErrRoomTooLarge   = errors.New("room too large")
+	ErrInvalidToken   = errors.New("invalid or expired token")
+	ErrTokenReused    = errors.New("refresh token reused, session revoked")
+	ErrWeakPassword   = errors.New("password must be 8-72 characters with letters and digits")
+	ErrWrongPassword  = errors.New("current password is incorrect")
+	ErrSamePassword   = errors.New("new password must differ from the current one")
+	ErrInvalidCode    = errors.New("invalid two-factor code")
+	ErrTooManyTries   = errors.New("too many invalid codes, log in again later")
+	ErrTOTPEnabled    = errors.New("two-factor authentication already enabled")
+	ErrTOTPDisabled   = errors.New("two-factor authentication is not enabled")
+	ErrEmailNotFound  = errors.New("email doesn't exist")
+	ErrEmailNotDead   = errors.New("only dead emails can be re-sent")
 )
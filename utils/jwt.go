@@ -2,54 +2,101 @@ package utils
 
 import (
 	"errors"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
+const (
+	AccessTokenTTL   = 15 * time.Minute
+	VerificationTTL  = 15 * time.Minute
+	RefreshTokenTTL  = 30 * 24 * time.Hour
+	PasswordResetTTL = 30 * time.Minute
+	MFAPendingTTL    = 5 * time.Minute
+
+	PurposePasswordReset = "password_reset"
+	PurposeMFAPending    = "mfa_pending"
+)
+
+// JWTManager signs and parses every token the app issues, built from config.JWTConfig
+type JWTManager struct {
+	secret []byte
+}
+
+func NewJWTManager(secret string) *JWTManager {
+	return &JWTManager{secret: []byte(secret)}
+}
 
 type JWTClaims struct {
 	UserID     uint   `json:"user_id"`
 	Email      string `json:"email"`
 	IsVerified bool   `json:"is_verified"`
+	SessionID  string `json:"sid,omitempty"`
+	Purpose    string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWTLogin(userID uint, email string) (string, error) {
+// GenerateJWTLogin issues a short-lived access token tied to a session, use the refresh token to get a new one
+func (j *JWTManager) GenerateJWTLogin(userID uint, email, sessionID string) (string, error) {
 	claims := JWTClaims{
 		UserID:     userID,
 		Email:      email,
 		IsVerified: true,
+		SessionID:  sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return token.SignedString(j.secret)
+}
+
+func (j *JWTManager) GenerateJWTVerification(email string) (string, error) {
+	claims := JWTClaims{
+		Email:      email,
+		IsVerified: false,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(VerificationTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(j.secret)
+}
+
+// GenerateJWTPasswordReset is single use, the caller stores its HashToken and burns it on reset
+func (j *JWTManager) GenerateJWTPasswordReset(email string) (string, error) {
+	return j.generatePurposeJWT(email, PurposePasswordReset, PasswordResetTTL)
+}
+
+// GenerateJWTMFAPending proves the password step passed, it is only accepted by the 2fa login step
+func (j *JWTManager) GenerateJWTMFAPending(email string) (string, error) {
+	return j.generatePurposeJWT(email, PurposeMFAPending, MFAPendingTTL)
 }
 
-func GenerateJWTVerification(email string) (string, error) {
+// purpose tokens are never verified, so JWTAuthMiddleware refuses them as access tokens
+func (j *JWTManager) generatePurposeJWT(email, purpose string, ttl time.Duration) (string, error) {
 	claims := JWTClaims{
 		Email:      email,
 		IsVerified: false,
+		Purpose:    purpose,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return token.SignedString(j.secret)
 }
 
-func ParseJWT(tokenString string) (*JWTClaims, error) {
+func (j *JWTManager) ParseJWT(tokenString string) (*JWTClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+		return j.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil {
 		return nil, err
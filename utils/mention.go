@@ -0,0 +1,53 @@
+package utils
+
+import "unicode"
+
+const (
+	MentionUser = "user"
+	MentionRoom = "room"
+	MentionHere = "here"
+)
+
+// MentionToken is one @name found in a message. Offset and Length count runes, not bytes
+type MentionToken struct {
+	Name   string
+	Offset int
+	Length int
+}
+
+// ParseMentions finds @name tokens, name being letters, digits, '_', '-' or '.'.
+// An @ glued to a word (like in an email address) is not a mention
+func ParseMentions(message string) []MentionToken {
+	runes := []rune(message)
+
+	var tokens []MentionToken
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '@' || (i > 0 && isMentionRune(runes[i-1])) {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && isMentionRune(runes[end]) {
+			end++
+		}
+		// "@budi." at the end of a sentence
+		for end > i+1 && runes[end-1] == '.' {
+			end--
+		}
+		if end == i+1 {
+			continue
+		}
+
+		tokens = append(tokens, MentionToken{
+			Name:   string(runes[i+1 : end]),
+			Offset: i,
+			Length: end - i,
+		})
+		i = end - 1
+	}
+	return tokens
+}
+
+func isMentionRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.'
+}
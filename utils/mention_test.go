@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    []MentionToken
+	}{
+		{"none", "halo semua", nil},
+		{"single", "halo @budi", []MentionToken{{Name: "budi", Offset: 5, Length: 5}}},
+		{"start of message", "@budi halo", []MentionToken{{Name: "budi", Offset: 0, Length: 5}}},
+		{"several", "@budi @siti_a dan @room", []MentionToken{
+			{Name: "budi", Offset: 0, Length: 5},
+			{Name: "siti_a", Offset: 6, Length: 7},
+			{Name: "room", Offset: 18, Length: 5},
+		}},
+		{"trailing dot dropped", "sudah @budi.", []MentionToken{{Name: "budi", Offset: 6, Length: 5}}},
+		{"dot inside name kept", "cc @budi.s ya", []MentionToken{{Name: "budi.s", Offset: 3, Length: 7}}},
+		{"dash", "@a-b", []MentionToken{{Name: "a-b", Offset: 0, Length: 4}}},
+		{"punctuation ends name", "(@budi), ok", []MentionToken{{Name: "budi", Offset: 1, Length: 5}}},
+		{"email is not a mention", "kirim ke budi@mail.com", nil},
+		{"bare at", "@ sendiri", nil},
+		{"only dots", "@...", nil},
+		{"double at", "@@budi", []MentionToken{{Name: "budi", Offset: 1, Length: 5}}},
+		// offset dan length dihitung per rune, bukan byte
+		{"multibyte before", "héllo @búdi", []MentionToken{{Name: "búdi", Offset: 6, Length: 5}}},
+		{"emoji before", "👋 @budi", []MentionToken{{Name: "budi", Offset: 2, Length: 5}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseMentions(tt.message); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMentions(%q) = %+v, want %+v", tt.message, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "chat"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, mux route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, mux route template and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "GORM query latency by operation.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation"})
+
+	messagesCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_created_total",
+		Help:      "Chat messages created, rate() of this is messages per second.",
+	})
+
+	realtimeConnections = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "realtime_connections",
+		Help:      "Open real-time connections by transport.",
+	}, []string{"transport"})
+
+	emailsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "emails_sent_total",
+		Help:      "Email send attempts by result (success, failure, dead).",
+	}, []string{"result"})
+)
+
+const (
+	TransportWebSocket = "websocket"
+	TransportSSE       = "sse"
+
+	EmailSuccess = "success"
+	EmailFailure = "failure"
+	// gagal dan sudah mencapai max attempts
+	EmailDead = "dead"
+)
+
+// route kosong (tidak ketemu di router) digabung supaya path acak tidak bikin label baru terus
+const unmatchedRoute = "unmatched"
+
+func ObserveHTTP(method, route string, status int, latency time.Duration) {
+	if route == "" {
+		route = unmatchedRoute
+	}
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, code).Inc()
+	httpDuration.WithLabelValues(method, route, code).Observe(latency.Seconds())
+}
+
+func MessageCreated() {
+	messagesCreated.Inc()
+}
+
+// ConnectionOpened returns the func that marks the connection closed again
+func ConnectionOpened(transport string) func() {
+	gauge := realtimeConnections.WithLabelValues(transport)
+	gauge.Inc()
+	return gauge.Dec
+}
+
+func EmailSent(result string) {
+	emailsSent.WithLabelValues(result).Inc()
+}
+
+// Handler serves every registered metric, Go runtime and process stats included.
+// With a token set the scraper has to send it as a bearer token
+func Handler(token string) http.Handler {
+	h := promhttp.Handler()
+	if token == "" {
+		return h
+	}
+
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"bufio"
+	"chat/utils/logger"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// request id dari client dipakai ulang kalau formatnya wajar, selain itu dibuatkan baru
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type requestStateKey struct{}
+
+// requestState diisi middleware yang jalan belakangan (mis. auth) lalu dibaca saat access log ditulis
+type requestState struct {
+	userID uint
+}
+
+// RequestLogger assigns a request ID, puts a logger carrying it in the context and writes one
+// access log line per request. Register it with Router.Use so the route template is known
+func RequestLogger(base *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			requestID := r.Header.Get(RequestIDHeader)
+			if !validRequestID.MatchString(requestID) {
+				requestID = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+
+			state := &requestState{}
+			l := base.With("request_id", requestID)
+			ctx := logger.WithContext(r.Context(), l)
+			ctx = context.WithValue(ctx, requestStateKey{}, state)
+
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			route := ""
+			if current := mux.CurrentRoute(r); current != nil {
+				route, _ = current.GetPathTemplate()
+			}
+
+			level := slog.LevelInfo
+			status := rec.statusCode()
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("route", route),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int64("bytes", rec.bytes),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("remote_addr", r.RemoteAddr),
+			}
+			if state.userID != 0 {
+				attrs = append(attrs, slog.Uint64("user_id", uint64(state.userID)))
+			}
+			l.LogAttrs(r.Context(), level, "request", attrs...)
+		})
+	}
+}
+
+// setRequestUser records the authenticated user for the access log and the request logger
+func setRequestUser(ctx context.Context, userID uint) context.Context {
+	if state, ok := ctx.Value(requestStateKey{}).(*requestState); ok {
+		state.userID = userID
+	}
+	return logger.WithContext(ctx, logger.FromContext(ctx).With("user_id", userID))
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// statusRecorder tetap harus bisa Flush (SSE) dan Hijack (websocket)
+type statusRecorder struct {
+	http.ResponseWriter
+	status   int
+	bytes    int64
+	hijacked bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		r.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap lets http.ResponseController reach the real writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *statusRecorder) statusCode() int {
+	switch {
+	case r.hijacked:
+		return http.StatusSwitchingProtocols
+	case r.status == 0:
+		return http.StatusOK
+	default:
+		return r.status
+	}
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"chat/utils/metrics"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// HTTPMetrics counts requests and their latency per route template, register it with Router.Use
+func HTTPMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		route := ""
+		if current := mux.CurrentRoute(r); current != nil {
+			route, _ = current.GetPathTemplate()
+		}
+		metrics.ObserveHTTP(r.Method, route, rec.statusCode(), time.Since(start))
+	})
+}
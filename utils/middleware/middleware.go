@@ -11,22 +11,77 @@ type key int
 
 const UserContextKey key = 0
 
-func JWTAuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Unauthorized: No token provided", http.StatusUnauthorized)
-			return
-		}
-
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		claims, err := utils.ParseJWT(tokenString)
-		if err != nil || !claims.IsVerified {
-			http.Error(w, "Unauthorized: Invalid or unverified user", http.StatusForbidden)
-			return
-		}
-
-		ctx := context.WithValue(r.Context(), UserContextKey, claims)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+// SessionChecker tells whether the session an access token was issued for is still alive
+type SessionChecker interface {
+	IsSessionActive(sessionID string) (bool, error)
+}
+
+// JWTAuthMiddleware rejects tokens without a session and tokens whose session was revoked by logout
+func JWTAuthMiddleware(jwt *utils.JWTManager, sessions SessionChecker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString := tokenFromRequest(r)
+			if tokenString == "" {
+				http.Error(w, "Unauthorized: No token provided", http.StatusUnauthorized)
+				return
+			}
+
+			claims, err := jwt.ParseJWT(tokenString)
+			if err != nil || !claims.IsVerified {
+				http.Error(w, "Unauthorized: Invalid or unverified user", http.StatusForbidden)
+				return
+			}
+
+			if claims.SessionID == "" {
+				http.Error(w, "Unauthorized: Session expired", http.StatusUnauthorized)
+				return
+			}
+			active, err := sessions.IsSessionActive(claims.SessionID)
+			if err != nil {
+				http.Error(w, "Failed to check session", http.StatusInternalServerError)
+				return
+			}
+			if !active {
+				http.Error(w, "Unauthorized: Session revoked", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := setRequestUser(r.Context(), claims.UserID)
+			ctx = context.WithValue(ctx, UserContextKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// browsers can't set headers on a websocket handshake, so upgrades may pass the token as ?token=.
+// The hub only accepts those upgrades from APP_ALLOWED_ORIGINS, see realtime.NewHub
+func tokenFromRequest(r *http.Request) string {
+	if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+		return strings.TrimPrefix(authHeader, "Bearer ")
+	}
+
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return r.URL.Query().Get("token")
+	}
+	return ""
+}
+
+// AdminOnly must run after JWTAuthMiddleware, only the listed user ids get through.
+// Not the email claim, a user can change their own email without re-verifying it
+func AdminOnly(adminUserIDs []uint) func(http.Handler) http.Handler {
+	admins := make(map[uint]bool, len(adminUserIDs))
+	for _, id := range adminUserIDs {
+		admins[id] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(UserContextKey).(*utils.JWTClaims)
+			if !ok || !admins[claims.UserID] {
+				http.Error(w, "Forbidden: admin only", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
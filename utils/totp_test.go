@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B, SHA1 seed "12345678901234567890". The RFC lists 8 digit codes,
+// the 6 digit code is the last 6 digits of the same value
+func TestValidateTOTP_RFC6238(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		step, ok := ValidateTOTP(secret, tt.code, time.Unix(tt.unix, 0))
+		if !ok {
+			t.Errorf("ValidateTOTP(%s, %d) = false, want true", tt.code, tt.unix)
+			continue
+		}
+		if want := tt.unix / totpPeriod; step != want {
+			t.Errorf("ValidateTOTP(%s, %d) step = %d, want %d", tt.code, tt.unix, step, want)
+		}
+	}
+}
+
+func TestValidateTOTP_Window(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	// 287082 is the code for step 1, unix 30-59
+	tests := []struct {
+		name   string
+		secret string
+		unix   int64
+		code   string
+		ok     bool
+	}{
+		{"same step", secret, 59, "287082", true},
+		{"one step early", secret, 0, "287082", true},
+		{"one step late", secret, 89, "287082", true},
+		{"two steps late", secret, 90, "287082", false},
+		{"wrong code", secret, 59, "287083", false},
+		{"too short", secret, 59, "28708", false},
+		{"8 digits", secret, 59, "94287082", false},
+		{"lowercase secret", strings.ToLower(secret), 59, "287082", true},
+		{"invalid secret", "not base32!", 59, "287082", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := ValidateTOTP(tt.secret, tt.code, time.Unix(tt.unix, 0)); ok != tt.ok {
+				t.Errorf("ValidateTOTP = %v, want %v", ok, tt.ok)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %v", secret, err)
+	}
+	if len(key) != 20 {
+		t.Errorf("key is %d bytes, want 20", len(key))
+	}
+
+	code := totpCode(key, time.Now().Unix()/totpPeriod)
+	if _, ok := ValidateTOTP(secret, code, time.Now()); !ok {
+		t.Errorf("code %s for a fresh secret is rejected", code)
+	}
+}
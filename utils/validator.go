@@ -1,9 +1,30 @@
 package utils
 
-import "regexp"
+import (
+	"regexp"
+	"unicode"
+)
 
 func IsValidEmail(email string) bool {
 	regex := `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`
 	re := regexp.MustCompile(regex)
 	return re.MatchString(email)
 }
+
+// IsValidPassword minimal 8 karakter, ada huruf dan angka
+func IsValidPassword(password string) bool {
+	if len(password) < 8 || len(password) > 72 {
+		return false
+	}
+
+	var hasLetter, hasDigit bool
+	for _, c := range password {
+		switch {
+		case unicode.IsLetter(c):
+			hasLetter = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		}
+	}
+	return hasLetter && hasDigit
+}